@@ -0,0 +1,158 @@
+function(task, responses){
+    // Sage writes every query message as a line of JSON:
+    // {"role": "user|assistant", "kind": "text|tool_call|tool_result|thinking|error", "tool": "...", "content": "...", "usage": {...}}
+    // The chat command writes each message as its own response in readable text for the interactive console instead:
+    // "👤> " or "🤖> " text, "🛠️ Tool Call: <tool>", "📦 Tool Result: <tool>", "🤔 Thinking", or "⚠️ " errors, with a
+    // "📊 <input> input tokens / <output> output tokens" line after the last message of an answer.
+    // Lines that are not messages (notices, approval requests, or a schema answer) are shown as they are.
+    const icons = {"user": "👤", "assistant": "🤖"};
+    const kinds = {
+        "tool_call": {"icon": "🛠️", "name": "Tool Call"},
+        "tool_result": {"icon": "📦", "name": "Tool Result"},
+        "thinking": {"icon": "🤔", "name": "Thinking"},
+        "error": {"icon": "⚠️", "name": "Error"},
+    };
+    const cellStyle = {"whiteSpace": "pre-wrap", "wordBreak": "break-word", "verticalAlign": "top"};
+
+    let rows = [];
+    let raw = [];
+    let input = 0;
+    let output = 0;
+
+    // text returns a row for prose, code blocks get their own row with a copy button
+    function text(icon, content, tokens){
+        const start = rows.length;
+        const parts = content.split(/```[a-zA-Z0-9_+-]*\n?/);
+        for(let i = 0; i < parts.length; i++){
+            const part = parts[i].replace(/^\n+|\n+$/g, "");
+            if(part === ""){
+                continue;
+            }
+            const code = i % 2 === 1;
+            rows.push({
+                "role": {"plaintext": code ? "" : icon},
+                "message": {
+                    "plaintext": part,
+                    "copyIcon": code,
+                    "cellStyle": code ? Object.assign({"fontFamily": "monospace", "backgroundColor": "rgba(127, 127, 127, 0.15)"}, cellStyle) : cellStyle,
+                },
+                "tokens": {"plaintext": ""},
+            });
+            icon = "";
+        }
+        if(rows.length > start && tokens !== ""){
+            rows[rows.length - 1]["tokens"]["plaintext"] = tokens;
+        }
+    }
+
+    // collapsed returns a row with a button that opens the full content
+    function collapsed(kind, tool, content, tokens){
+        const title = tool ? kind["name"] + ": " + tool : kind["name"];
+        rows.push({
+            "role": {"plaintext": kind["icon"]},
+            "message": {
+                "button": {
+                    "name": title,
+                    "type": "string",
+                    "value": content,
+                    "title": title,
+                    "hoverText": "Show the " + kind["name"].toLowerCase(),
+                },
+            },
+            "tokens": {"plaintext": tokens},
+        });
+    }
+
+    // consoleMessage returns the message in a chat response, or null if the response is not one
+    function consoleMessage(response){
+        let content = response.replace(/\n?👤> $/, "").replace(/\n+$/, "");
+        let msg = {"role": "assistant", "kind": "text", "tool": ""};
+        const usage = content.match(/\n📊 (\d+) input tokens \/ (\d+) output tokens$/);
+        if(usage !== null){
+            msg["usage"] = {"input_tokens": parseInt(usage[1]), "output_tokens": parseInt(usage[2])};
+            content = content.slice(0, usage.index);
+        }
+        const header = content.match(/^(🛠️ Tool Call|📦 Tool Result): ([^\n]*)\n|^🤔 Thinking\n/);
+        if(header !== null){
+            msg["kind"] = header[0].startsWith("🛠️") ? "tool_call" : header[0].startsWith("📦") ? "tool_result" : "thinking";
+            msg["tool"] = header[2] || "";
+            msg["content"] = content.slice(header[0].length);
+        } else if(content.startsWith("👤> ")){
+            msg["role"] = "user";
+            msg["content"] = content.slice("👤> ".length);
+        } else if(content.startsWith("🤖> ")){
+            msg["content"] = content.slice("🤖> ".length);
+        } else if(content.startsWith("⚠️ ") && usage === null && !content.includes("/approve")){
+            msg["kind"] = "error";
+            msg["content"] = content.slice("⚠️ ".length);
+        } else {
+            return null;
+        }
+        return msg;
+    }
+
+    // add adds the rows for a message
+    function add(msg){
+        let tokens = "";
+        if(msg["usage"]){
+            input += msg["usage"]["input_tokens"];
+            output += msg["usage"]["output_tokens"];
+            tokens = msg["usage"]["input_tokens"] + " in / " + msg["usage"]["output_tokens"] + " out";
+        }
+        if(msg["kind"] in kinds && msg["kind"] !== "error"){
+            collapsed(kinds[msg["kind"]], msg["tool"], msg["content"], tokens);
+        } else if(msg["kind"] === "error"){
+            text(kinds["error"]["icon"], msg["content"], tokens);
+        } else {
+            text(icons[msg["role"]] || "", msg["content"], tokens);
+        }
+    }
+
+    for(let i = 0; i < responses.length; i++){
+        const chat = consoleMessage(responses[i]);
+        if(chat !== null){
+            add(chat);
+            continue;
+        }
+        const lines = responses[i].split("\n");
+        for(let j = 0; j < lines.length; j++){
+            const line = lines[j];
+            if(line.trim() === "" || line.trim() === "👤>"){
+                continue;
+            }
+            let msg = null;
+            try {
+                msg = JSON.parse(line);
+            } catch(error) {
+                msg = null;
+            }
+            if(msg === null || typeof msg !== "object" || !("role" in msg) || !("content" in msg)){
+                raw.push(line);
+                rows.push({"role": {"plaintext": "ℹ️"}, "message": {"plaintext": line, "cellStyle": cellStyle}, "tokens": {"plaintext": ""}});
+                continue;
+            }
+            add(msg);
+        }
+    }
+
+    // Keep the original output for responses that are not a Sage conversation, like a schema answer
+    if(rows.length === 0 || rows.length === raw.length){
+        return {"plaintext": responses.join("")};
+    }
+
+    let title = "Sage";
+    if(input > 0 || output > 0){
+        title += " - " + input + " input tokens / " + output + " output tokens";
+    }
+    return {
+        "table": [{
+            "headers": [
+                {"plaintext": "role", "type": "string", "width": 70, "disableSort": true},
+                {"plaintext": "message", "type": "string", "fillWidth": true, "disableSort": true},
+                {"plaintext": "tokens", "type": "string", "width": 180, "disableSort": true},
+            ],
+            "rows": rows,
+            "title": title,
+        }],
+    };
+}
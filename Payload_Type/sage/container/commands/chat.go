@@ -3,13 +3,18 @@ package commands
 import (
 	// Standard
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 
 	// Internal
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/anthropic"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/bedrock"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/message"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mythic"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/openai"
 
 	// Mythic
@@ -52,15 +57,15 @@ func chat() structs.Command {
 		Name:                                    "model",
 		ModalDisplayName:                        "model",
 		CLIName:                                 "model",
-		ParameterType:                           structs.COMMAND_PARAMETER_TYPE_STRING,
-		Description:                             "The model to use for inference from the selected provider",
+		ParameterType:                           structs.COMMAND_PARAMETER_TYPE_CHOOSE_ONE_CUSTOM,
+		Description:                             "The model to use for inference from the selected provider. Choose one of the provider's models or enter a model ID",
 		DefaultValue:                            "",
 		SupportedAgents:                         nil,
 		SupportedAgentBuildParameters:           nil,
 		ChoicesAreAllCommands:                   false,
 		ChoicesAreLoadedCommands:                false,
 		FilterCommandChoicesByCommandAttributes: nil,
-		DynamicQueryFunction:                    nil,
+		DynamicQueryFunction:                    GetModelList,
 		ParameterGroupInformation: []structs.ParameterGroupInfo{
 			{
 				ParameterIsRequired:   false,
@@ -228,6 +233,12 @@ func chat() structs.Command {
 		},
 	}
 
+	toolsAllow, toolsDeny, toolsConfirm := toolFilterParameters(11, "Default")
+	agentCallbacks, agentCommands := agentTaskingParameters(14, "Default")
+	record := recordParameter(16, "Default")
+	profile := profileParameter(17, "Default")
+	mythicTools, mythicSecrets := mythicToolParameters(18, "Default")
+
 	command := structs.Command{
 		Name:                           "chat",
 		NeedsAdminPermissions:          false,
@@ -239,8 +250,8 @@ func chat() structs.Command {
 		MitreAttackMappings:            []string{},
 		ScriptOnlyCommand:              false,
 		CommandAttributes:              attr,
-		CommandParameters:              []structs.CommandParameter{provider, model, prompt, tools, verbose, apiEndpoint, apiKey, awsAccessKey, awsSecretAccessKey, awsSessionToken, awsRegion},
-		AssociatedBrowserScript:        nil,
+		CommandParameters:              []structs.CommandParameter{provider, model, prompt, tools, verbose, apiEndpoint, apiKey, awsAccessKey, awsSecretAccessKey, awsSessionToken, awsRegion, toolsAllow, toolsDeny, toolsConfirm, agentCallbacks, agentCommands, record, profile, mythicTools, mythicSecrets},
+		AssociatedBrowserScript:        transcriptBrowserScript(),
 		TaskFunctionOPSECPre:           nil,
 		TaskFunctionCreateTasking:      chatCreateTask,
 		TaskFunctionProcessResponse:    nil,
@@ -310,6 +321,14 @@ func chatCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCrea
 		task.Args.SetArgValue("provider", chatParams.Provider)
 		task.Args.SetArgValue("model", chatParams.Model)
 		task.Args.SetArgValue("tools", chatParams.Tools)
+		task.Args.SetArgValue("tools_allow", toInterfaceSlice(chatParams.ToolsAllow))
+		task.Args.SetArgValue("tools_deny", toInterfaceSlice(chatParams.ToolsDeny))
+		task.Args.SetArgValue("tools_confirm", toInterfaceSlice(chatParams.ToolsConfirm))
+		task.Args.SetArgValue(mythic.CallbacksArg, toInterfaceSlice(chatParams.AgentCallbacks))
+		task.Args.SetArgValue(mythic.CommandsArg, toInterfaceSlice(chatParams.AgentCommands))
+		task.Args.SetArgValue(mythic.ToolsArg, chatParams.MythicTools)
+		task.Args.SetArgValue(mythic.SecretsArg, chatParams.MythicSecrets)
+		task.Args.SetArgValue(mcp.RecordArg, toInterfaceSlice(chatParams.Record))
 		task.Args.SetArgValue("verbose", chatParams.Verbose)
 		task.Args.SetArgValue("API_ENDPOINT", chatParams.Endpoint)
 		task.Args.SetArgValue("API_KEY", chatParams.Key)
@@ -317,6 +336,7 @@ func chatCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCrea
 		task.Args.SetArgValue("AWS_SECRET_ACCESS_KEY", chatParams.AWSSecretAccessKey)
 		task.Args.SetArgValue("AWS_SESSION_TOKEN", chatParams.AWSSessionToken)
 		task.Args.SetArgValue("AWS_DEFAULT_REGION", chatParams.AWSDefaultRegion)
+		task.Args.SetArgValue(env.ProfileKey, chatParams.Profile)
 
 		provider = chatParams.Provider
 		model = chatParams.Model
@@ -327,7 +347,32 @@ func chatCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCrea
 		case InteractiveTask.Input:
 			// Handle input messages
 			prompt = task.Args.GetCommandLine()
+
+			// Answer a tool call that is waiting for the operator's approval instead of sending a new prompt
+			var notice string
+			if decision, ok := approvalDecision(prompt); ok {
+				if err = mcp.Resolve(parentTask.ID, decision); err != nil {
+					notice = fmt.Sprintf("⚠️ %s\n👤> ", err)
+				}
+			} else if mcp.Pending(parentTask.ID) {
+				notice = "⚠️ A tool call is waiting for approval, reply /approve or /deny <reason>\n👤> "
+			} else {
+				break
+			}
+			if notice != "" {
+				_, err = mythicrpc.SendMythicRPCResponseCreate(mythicrpc.MythicRPCResponseCreateMessage{
+					TaskID:   parentTask.ID,
+					Response: []byte(notice),
+				})
+				if err != nil {
+					logging.LogError(err, pkg)
+				}
+			}
+			resp.Success = true
+			return
 		case InteractiveTask.Exit:
+			// Release a tool call that is still waiting so the chat loop can finish
+			mcp.Resolve(parentTask.ID, mcp.Decision{Reason: "the operator exited the chat session"})
 			sessions.Delete(parentTask.ID)
 			resp.Success = true
 			t := true
@@ -362,7 +407,7 @@ func chatCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCrea
 
 		respMsg := mythicrpc.MythicRPCResponseCreateMessage{
 			TaskID:   task.Task.ID,
-			Response: []byte(fmt.Sprintf("👤> %s\n", prompt)),
+			Response: message.Console(message.Message{Role: message.User, Content: prompt}),
 		}
 
 		_, err = mythicrpc.SendMythicRPCResponseCreate(respMsg)
@@ -384,8 +429,8 @@ func chatCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCrea
 	case "anthropic":
 		output, err = anthropic.Chat(task, sessions.GetMessages(resp.TaskID), tools, verbose)
 	case "bedrock":
-		if !strings.Contains(model, ".anthropic.") {
-			err = fmt.Errorf("⚠️ model '%s' is not supported by Bedrock", model)
+		if _, err = bedrock.ValidateModel(task, model); err != nil {
+			err = fmt.Errorf("⚠️ %s", err)
 			resp.Error = err.Error()
 			resp.Success = false
 			logging.LogError(err, pkg)
@@ -403,7 +448,7 @@ func chatCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCrea
 	if err != nil {
 		msg := mythicrpc.MythicRPCResponseCreateMessage{
 			TaskID:   resp.TaskID,
-			Response: []byte(fmt.Sprintf("⚠️ %s\n", err.Error())),
+			Response: message.Console(message.Message{Role: message.Assistant, Kind: message.Error, Content: err.Error()}),
 		}
 
 		r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
@@ -413,6 +458,8 @@ func chatCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCrea
 			logging.LogError(err, pkg)
 			return
 		}
+	} else {
+		mythic.Record(task, provider, model, output)
 	}
 
 	// Store the assistant message in the session and send the response to the user
@@ -424,14 +471,14 @@ func chatCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCrea
 		sessions.UpdateMessages(resp.TaskID, m)
 
 		if verbose {
-			x := fmt.Sprintf("🤖> %s\n", o.Content)
+			x := message.Console(o)
 			// If it is the last message add the user prompt icon
 			if k == len(output)-1 {
-				x = fmt.Sprintf("🤖> %s\n👤> ", o.Content)
+				x = append(x, []byte("👤> ")...)
 			}
 			msg := mythicrpc.MythicRPCResponseCreateMessage{
 				TaskID:   resp.TaskID,
-				Response: []byte(x),
+				Response: x,
 			}
 
 			r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
@@ -444,7 +491,7 @@ func chatCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCrea
 		} else if k == len(output)-1 {
 			msg := mythicrpc.MythicRPCResponseCreateMessage{
 				TaskID:   resp.TaskID,
-				Response: []byte(fmt.Sprintf("🤖> %s\n👤> ", o.Content)),
+				Response: append(message.Console(o), []byte("👤> ")...),
 			}
 
 			r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
@@ -480,6 +527,14 @@ type Chat struct {
 	Messages           []message.Message `json:"messages"`
 	Count              int               `json:"count"` // Number of messages in the chat
 	Tools              bool              `json:"tools"`
+	ToolsAllow         []string          `json:"tools_allow"`
+	ToolsDeny          []string          `json:"tools_deny"`
+	ToolsConfirm       []string          `json:"tools_confirm"`
+	AgentCallbacks     []string          `json:"agent_callbacks"`
+	AgentCommands      []string          `json:"agent_commands"`
+	MythicTools        bool              `json:"mythic_tools"`
+	MythicSecrets      bool              `json:"mythic_secrets"`
+	Record             []string          `json:"record"`
 	Verbose            bool              `json:"verbose"`
 	Endpoint           string            `json:"API_ENDPOINT"`
 	Key                string            `json:"API_KEY"`
@@ -487,6 +542,7 @@ type Chat struct {
 	AWSSecretAccessKey string            `json:"AWS_SECRET_ACCESS_KEY"`
 	AWSSessionToken    string            `json:"AWS_SESSION_TOKEN"`
 	AWSDefaultRegion   string            `json:"AWS_DEFAULT_REGION"`
+	Profile            string            `json:"profile"`
 }
 
 func NewChat(task *structs.PTTaskMessageAllData) (chat Chat, err error) {
@@ -506,6 +562,15 @@ func NewChat(task *structs.PTTaskMessageAllData) (chat Chat, err error) {
 	if err != nil {
 		return
 	}
+	filter := mcp.NewToolFilter(task)
+	chat.ToolsAllow = filter.Allow
+	chat.ToolsDeny = filter.Deny
+	chat.ToolsConfirm = filter.Confirm
+	chat.AgentCallbacks, _ = task.Args.GetChooseMultipleArg(mythic.CallbacksArg)
+	chat.AgentCommands, _ = task.Args.GetArrayArg(mythic.CommandsArg)
+	chat.MythicTools, _ = task.Args.GetBooleanArg(mythic.ToolsArg)
+	chat.MythicSecrets, _ = task.Args.GetBooleanArg(mythic.SecretsArg)
+	chat.Record, _ = task.Args.GetChooseMultipleArg(mcp.RecordArg)
 
 	// If the key is empty, an error will be returned. It is OK if the key is empty for some providers
 	chat.Endpoint, _ = env.Get(task, "API_ENDPOINT")
@@ -514,6 +579,8 @@ func NewChat(task *structs.PTTaskMessageAllData) (chat Chat, err error) {
 	chat.AWSSecretAccessKey, _ = env.Get(task, "AWS_SECRET_ACCESS_KEY")
 	chat.AWSSessionToken, _ = env.Get(task, "AWS_SESSION_TOKEN")
 	chat.AWSDefaultRegion, _ = env.Get(task, "AWS_DEFAULT_REGION")
+	// The profile is kept so keys that are resolved later, like the AWS role, still come from it
+	chat.Profile, _ = task.Args.GetChooseOneArg(env.ProfileKey)
 
 	return chat, nil
 }
@@ -544,6 +611,17 @@ func (r *Repository) Delete(taskID int) {
 	delete(r.chats, taskID)
 }
 
+// IDs returns the task IDs of all the active chat sessions as strings
+func (r *Repository) IDs() (ids []string) {
+	r.Lock()
+	defer r.Unlock()
+	for id := range r.chats {
+		ids = append(ids, strconv.Itoa(id))
+	}
+	slices.Sort(ids)
+	return
+}
+
 func (r *Repository) GetMessages(taskID int) []message.Message {
 	r.Lock()
 	defer r.Unlock()
@@ -564,3 +642,25 @@ func (r *Repository) UpdateMessages(taskID int, msg message.Message) {
 		r.chats[taskID] = chat
 	}
 }
+
+// approvalDecision parses an "/approve" or "/deny <reason>" reply to a tool call that requires the operator's approval
+func approvalDecision(input string) (decision mcp.Decision, ok bool) {
+	command, reason, _ := strings.Cut(strings.TrimSpace(input), " ")
+	switch strings.ToLower(command) {
+	case "/approve":
+		decision.Approved = true
+	case "/deny":
+	default:
+		return decision, false
+	}
+	decision.Reason = strings.TrimSpace(reason)
+	return decision, true
+}
+
+// toInterfaceSlice converts a string slice to the []interface{} type Mythic uses for array task argument values
+func toInterfaceSlice(values []string) (i []interface{}) {
+	for _, v := range values {
+		i = append(i, v)
+	}
+	return
+}
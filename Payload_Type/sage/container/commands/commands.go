@@ -4,10 +4,19 @@ package commands
 import (
 	// Standard
 	"fmt"
+	"path/filepath"
 	"strings"
 
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/awsconfig"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/models"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mythic"
+
 	// Mythic
 	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
 	"github.com/MythicMeta/MythicContainer/mythicrpc"
 )
 
@@ -25,7 +34,8 @@ type Job struct {
 func Commands() (commands []structs.Command) {
 	// TODO Add the following commands: sharpgen
 	commands = append(
-		commands, chat(), list(), query(), mcpConnect(),
+		commands, chat(), list(), query(), mcpConnect(), mcpList(), mcpStatus(), mcpDisconnect(), mcpRestart(),
+		mcpResources(), mcpPrompts(), mcpAttach(), saveCredentials(), config(),
 	)
 	return
 }
@@ -203,3 +213,305 @@ func GetFileName(fileID string) (name string, err error) {
 	}
 	return
 }
+
+// GetMCPClientList returns the IDs of all the connected MCP clients
+// This function is used as a DynamicQuery to populate Mythic Command Parameter dropdown lists
+func GetMCPClientList(msg structs.PTRPCDynamicQueryFunctionMessage) (ids []string) {
+	return mcp.IDs()
+}
+
+// GetMCPServerList returns the unique IDs of all connected MCP clients followed by the MCP servers that are pending
+// because they could not be started when the container started
+// This function is used as a DynamicQuery to populate Mythic Command Parameter dropdown lists
+func GetMCPServerList(msg structs.PTRPCDynamicQueryFunctionMessage) (ids []string) {
+	ids = mcp.IDs()
+	for _, p := range mcp.PendingServers() {
+		ids = append(ids, p.ID.String())
+	}
+	return
+}
+
+// GetMCPToolList returns a glob pattern for each connected MCP server followed by the name of every MCP tool
+// This function is used as a DynamicQuery to populate Mythic Command Parameter dropdown lists
+func GetMCPToolList(msg structs.PTRPCDynamicQueryFunctionMessage) (tools []string) {
+	return mcp.ToolChoices()
+}
+
+// GetMCPPromptList returns the name of every MCP prompt prefixed with its server's alias
+// This function is used as a DynamicQuery to populate Mythic Command Parameter dropdown lists
+func GetMCPPromptList(msg structs.PTRPCDynamicQueryFunctionMessage) (prompts []string) {
+	return mcp.PromptChoices()
+}
+
+// GetChatSessionList returns the task IDs of all the active chat sessions
+// This function is used as a DynamicQuery to populate Mythic Command Parameter dropdown lists
+func GetChatSessionList(msg structs.PTRPCDynamicQueryFunctionMessage) (ids []string) {
+	return sessions.IDs()
+}
+
+// GetCallbackList returns the active callbacks the model can be allowed to task
+// This function is used as a DynamicQuery to populate Mythic Command Parameter dropdown lists
+func GetCallbackList(msg structs.PTRPCDynamicQueryFunctionMessage) (callbacks []string) {
+	return mythic.CallbackChoices(msg.Callback)
+}
+
+// modelCache holds the models listed for the model parameter's choices by provider, endpoint, and credentials
+var modelCache = models.NewCache[models.Model]()
+
+// modelKeys are the settings, in addition to the AWS settings, that change which models a provider lists
+var modelKeys = []string{"API_ENDPOINT", "API_KEY", "ANTHROPIC_API_KEY", "ANTHROPIC_AUTH_TOKEN"}
+
+// GetModelList returns the IDs of the models offered by the provider selected in the task modal, or resolved through
+// env.Get for the callback, using the callback's endpoint and credentials. The models are cached for models.TTL.
+// This function is used as a DynamicQuery to populate Mythic Command Parameter dropdown lists
+func GetModelList(msg structs.PTRPCDynamicQueryFunctionMessage) (ids []string) {
+	task := dynamicQueryTask(msg)
+
+	provider, err := env.Get(task, "provider")
+	if err != nil {
+		logging.LogError(err, "unable to list models for the model parameter without a provider")
+		return
+	}
+	provider = strings.ToLower(provider)
+
+	values := []string{provider}
+	for _, key := range append(modelKeys, awsconfig.Keys...) {
+		// Every setting is optional
+		value, _ := env.Get(task, key)
+		values = append(values, key+"="+value)
+	}
+
+	var list []models.Model
+	if provider == "bedrock" {
+		// Bedrock caches the models it lists, by region and credentials, for the same TTL to validate model strings
+		list, err = listModels(task, provider)
+	} else {
+		list, err = modelCache.Get(models.Key(values...), func() ([]models.Model, error) {
+			return listModels(task, provider)
+		})
+	}
+	if err != nil {
+		logging.LogError(err, "there was an error listing models for the model parameter", "Provider", provider)
+		return
+	}
+	for _, m := range list {
+		ids = append(ids, m.ID)
+	}
+	return
+}
+
+// dynamicQueryTask returns a task holding the values env.Get uses to resolve settings for a dynamic query: the other
+// parameters already entered in the task modal, the operator's secrets, and the build parameters of the callback's payload
+func dynamicQueryTask(msg structs.PTRPCDynamicQueryFunctionMessage) *structs.PTTaskMessageAllData {
+	task := &structs.PTTaskMessageAllData{Secrets: msg.Secrets}
+	for name, value := range msg.OtherParameters {
+		if v, ok := value.(string); ok {
+			task.Args.AddArg(structs.CommandParameter{Name: name, ParameterType: structs.COMMAND_PARAMETER_TYPE_STRING, DefaultValue: v})
+		}
+	}
+
+	if msg.PayloadUUID == "" {
+		return task
+	}
+	resp, err := mythicrpc.SendMythicRPCPayloadSearch(mythicrpc.MythicRPCPayloadSearchMessage{PayloadUUID: msg.PayloadUUID})
+	if err != nil || !resp.Success {
+		if err == nil {
+			err = fmt.Errorf("%s", resp.Error)
+		}
+		logging.LogError(err, "there was an error getting the callback's payload build parameters", "Payload", msg.PayloadUUID)
+		return task
+	}
+	for _, payload := range resp.PayloadConfigurations {
+		if payload.BuildParameters == nil {
+			continue
+		}
+		for _, param := range *payload.BuildParameters {
+			task.BuildParameters = append(task.BuildParameters, structs.PayloadConfigurationBuildParameter{Name: param.Name, Value: param.Value})
+		}
+	}
+	return task
+}
+
+// GetProfileList returns the names of the provider profiles in the profiles file
+// This function is used as a DynamicQuery to populate Mythic Command Parameter dropdown lists
+func GetProfileList(msg structs.PTRPCDynamicQueryFunctionMessage) (names []string) {
+	return append([]string{""}, env.ProfileNames()...)
+}
+
+// profileParameter returns the command parameter used to select a named provider profile from the profiles file.
+// The parameter is added to every parameter group in groups at the provided UI modal position.
+func profileParameter(position uint32, groups ...string) (profile structs.CommandParameter) {
+	profile = structs.CommandParameter{
+		Name:                 env.ProfileKey,
+		ModalDisplayName:     "Provider Profile",
+		CLIName:              "profile",
+		ParameterType:        structs.COMMAND_PARAMETER_TYPE_CHOOSE_ONE,
+		Description:          "[OPTIONAL] The named provider profile from the profiles file whose provider, endpoint, model, credentials, and defaults are used for any value not provided with the task",
+		Choices:              []string{""},
+		DefaultValue:         "",
+		DynamicQueryFunction: GetProfileList,
+	}
+	for _, group := range groups {
+		profile.ParameterGroupInformation = append(profile.ParameterGroupInformation, structs.ParameterGroupInfo{
+			ParameterIsRequired: false,
+			GroupName:           group,
+			UIModalPosition:     position,
+		})
+	}
+	return
+}
+
+// toolFilterParameters returns the command parameters used to select the MCP tools given to the model.
+// The parameters are added to every parameter group in groups, starting at the provided UI modal position.
+func toolFilterParameters(position uint32, groups ...string) (allow structs.CommandParameter, deny structs.CommandParameter, confirm structs.CommandParameter) {
+	allow = structs.CommandParameter{
+		Name:                 "tools_allow",
+		ModalDisplayName:     "Allowed Tools",
+		CLIName:              "tools-allow",
+		ParameterType:        structs.COMMAND_PARAMETER_TYPE_CHOOSE_MULTIPLE,
+		Description:          "[OPTIONAL] The MCP tools, or glob patterns (e.g., bloodhound__*), the model can use. Leave empty to allow every tool",
+		Choices:              []string{},
+		DefaultValue:         []string{},
+		DynamicQueryFunction: GetMCPToolList,
+	}
+	deny = structs.CommandParameter{
+		Name:                 "tools_deny",
+		ModalDisplayName:     "Denied Tools",
+		CLIName:              "tools-deny",
+		ParameterType:        structs.COMMAND_PARAMETER_TYPE_CHOOSE_MULTIPLE,
+		Description:          "[OPTIONAL] The MCP tools, or glob patterns (e.g., *__delete_*), the model can not use. Takes precedence over the allowed tools",
+		Choices:              []string{},
+		DefaultValue:         []string{},
+		DynamicQueryFunction: GetMCPToolList,
+	}
+	confirm = structs.CommandParameter{
+		Name:                 "tools_confirm",
+		ModalDisplayName:     "Confirm Tools",
+		CLIName:              "tools-confirm",
+		ParameterType:        structs.COMMAND_PARAMETER_TYPE_CHOOSE_MULTIPLE,
+		Description:          "[OPTIONAL] The MCP tools, or glob patterns, that pause the chat until the operator replies /approve or /deny. Calls are denied outside of an interactive chat",
+		Choices:              []string{},
+		DefaultValue:         []string{},
+		DynamicQueryFunction: GetMCPToolList,
+	}
+	for _, group := range groups {
+		allow.ParameterGroupInformation = append(allow.ParameterGroupInformation, structs.ParameterGroupInfo{
+			ParameterIsRequired: false,
+			GroupName:           group,
+			UIModalPosition:     position,
+		})
+		deny.ParameterGroupInformation = append(deny.ParameterGroupInformation, structs.ParameterGroupInfo{
+			ParameterIsRequired: false,
+			GroupName:           group,
+			UIModalPosition:     position + 1,
+		})
+		confirm.ParameterGroupInformation = append(confirm.ParameterGroupInformation, structs.ParameterGroupInfo{
+			ParameterIsRequired: false,
+			GroupName:           group,
+			UIModalPosition:     position + 2,
+		})
+	}
+	return
+}
+
+// agentTaskingParameters returns the command parameters used to allow the model to task other callbacks with the
+// mythic__task_callback tool. The tool is only given to the model when both parameters have a value.
+// The parameters are added to every parameter group in groups, starting at the provided UI modal position.
+func agentTaskingParameters(position uint32, groups ...string) (callbacks structs.CommandParameter, commands structs.CommandParameter) {
+	callbacks = structs.CommandParameter{
+		Name:                 mythic.CallbacksArg,
+		ModalDisplayName:     "Allowed Callbacks",
+		CLIName:              "agent-callbacks",
+		ParameterType:        structs.COMMAND_PARAMETER_TYPE_CHOOSE_MULTIPLE,
+		Description:          "[OPTIONAL] The callbacks the model can task with the mythic__task_callback tool. Every task requires the operator's approval",
+		Choices:              []string{},
+		DefaultValue:         []string{},
+		DynamicQueryFunction: GetCallbackList,
+	}
+	commands = structs.CommandParameter{
+		Name:             mythic.CommandsArg,
+		ModalDisplayName: "Allowed Commands",
+		CLIName:          "agent-commands",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_ARRAY,
+		Description:      "[OPTIONAL] The commands, or glob patterns, the model can issue to the allowed callbacks (e.g., ls, ps)",
+		DefaultValue:     []string{},
+	}
+	for _, group := range groups {
+		callbacks.ParameterGroupInformation = append(callbacks.ParameterGroupInformation, structs.ParameterGroupInfo{
+			ParameterIsRequired: false,
+			GroupName:           group,
+			UIModalPosition:     position,
+		})
+		commands.ParameterGroupInformation = append(commands.ParameterGroupInformation, structs.ParameterGroupInfo{
+			ParameterIsRequired: false,
+			GroupName:           group,
+			UIModalPosition:     position + 1,
+		})
+	}
+	return
+}
+
+// mythicToolParameters returns the command parameters that give the model the built-in Mythic search tools and
+// allow the search_credentials tool to return credential values. Both are off by default.
+// The parameters are added to every parameter group in groups, starting at the provided UI modal position.
+func mythicToolParameters(position uint32, groups ...string) (tools structs.CommandParameter, secrets structs.CommandParameter) {
+	tools = structs.CommandParameter{
+		Name:             mythic.ToolsArg,
+		ModalDisplayName: "Mythic Tools",
+		CLIName:          "mythic-tools",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_BOOLEAN,
+		DefaultValue:     false,
+		Description:      "[OPTIONAL] Give the model the built-in mythic__ tools that search the operation's callbacks, tasks, files, credentials, processes, tags, and artifacts",
+	}
+	secrets = structs.CommandParameter{
+		Name:             mythic.SecretsArg,
+		ModalDisplayName: "Reveal Credential Secrets",
+		CLIName:          "mythic-secrets",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_BOOLEAN,
+		DefaultValue:     false,
+		Description:      "[OPTIONAL] Let the mythic__search_credentials tool return credential values, which sends them to the model provider, instead of redacting them",
+	}
+	for _, group := range groups {
+		tools.ParameterGroupInformation = append(tools.ParameterGroupInformation, structs.ParameterGroupInfo{
+			ParameterIsRequired: false,
+			GroupName:           group,
+			UIModalPosition:     position,
+		})
+		secrets.ParameterGroupInformation = append(secrets.ParameterGroupInformation, structs.ParameterGroupInfo{
+			ParameterIsRequired: false,
+			GroupName:           group,
+			UIModalPosition:     position + 1,
+		})
+	}
+	return
+}
+
+// recordParameter returns the command parameter used to select the model activity that is written back to Mythic.
+// The parameter is added to every parameter group in groups at the provided UI modal position.
+func recordParameter(position uint32, groups ...string) (record structs.CommandParameter) {
+	record = structs.CommandParameter{
+		Name:             mcp.RecordArg,
+		ModalDisplayName: "Record Activity",
+		CLIName:          "record",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_CHOOSE_MULTIPLE,
+		Description:      "[OPTIONAL] Record the model's activity in Mythic: tag the task with the provider, model, and tokens, add a summary of the answer to the event log, and register an artifact for every MCP tool call",
+		Choices:          mcp.RecordChoices(),
+		DefaultValue:     []string{},
+	}
+	for _, group := range groups {
+		record.ParameterGroupInformation = append(record.ParameterGroupInformation, structs.ParameterGroupInfo{
+			ParameterIsRequired: false,
+			GroupName:           group,
+			UIModalPosition:     position,
+		})
+	}
+	return
+}
+
+// transcriptBrowserScript returns the browser script that renders the JSON lines written by message.Response as a conversation
+func transcriptBrowserScript() *structs.BrowserScript {
+	return &structs.BrowserScript{
+		ScriptPath: filepath.Join(".", "..", "browser_scripts", "transcript.js"),
+		Author:     "@Ne0nd0g",
+	}
+}
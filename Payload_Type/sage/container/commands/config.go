@@ -0,0 +1,166 @@
+package commands
+
+import (
+	// Standard
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/awsconfig"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+// configKeys are the settings Sage resolves with env.Get, in the order they are shown
+var configKeys = append([]string{env.ProfileKey, "provider", "model", "API_ENDPOINT", "API_KEY", "ANTHROPIC_API_KEY", "ANTHROPIC_AUTH_TOKEN", "MCP_BEARER_TOKEN"}, awsconfig.Keys...)
+
+// secretKeys are the settings whose values are redacted
+var secretKeys = []string{"API_KEY", "ANTHROPIC_API_KEY", "ANTHROPIC_AUTH_TOKEN", "MCP_BEARER_TOKEN", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_ROLE_EXTERNAL_ID"}
+
+// awsRegion matches AWS region names like us-east-1 or us-gov-west-1
+var awsRegion = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+func config() structs.Command {
+	attr := structs.CommandAttribute{
+		SupportedOS: []string{"sage"},
+	}
+
+	command := structs.Command{
+		Name:                           "config",
+		NeedsAdminPermissions:          false,
+		HelpString:                     "config -profile <profile>",
+		Description:                    "Show the value Sage resolves for each setting, where it came from (task arg, provider profile, user secret, build parameter, or container env), and any value that is not valid. Secrets are redacted",
+		Version:                        0,
+		SupportedUIFeatures:            nil,
+		Author:                         "@Ne0nd0g",
+		MitreAttackMappings:            []string{},
+		ScriptOnlyCommand:              false,
+		CommandAttributes:              attr,
+		CommandParameters:              []structs.CommandParameter{profileParameter(0, "Default")},
+		AssociatedBrowserScript:        nil,
+		TaskFunctionOPSECPre:           nil,
+		TaskFunctionParseArgString:     taskFunctionParseArgString,
+		TaskFunctionParseArgDictionary: taskFunctionParseArgDictionary,
+		TaskFunctionCreateTasking:      configCreateTask,
+		TaskFunctionProcessResponse:    nil,
+		TaskFunctionOPSECPost:          nil,
+		TaskCompletionFunctions:        nil,
+	}
+
+	return command
+}
+
+func configCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCreateTaskingMessageResponse) {
+	resp.TaskID = task.Task.ID
+
+	values := make(map[string]string)
+	problems := 0
+	stdout := fmt.Sprintf("Provider profiles file: %s\n\n", env.ProfilesFilePath())
+	stdout += "| Key | Value | Source | Problem |\n|---|---|---|---|\n"
+	for _, key := range configKeys {
+		value, source, err := env.Lookup(task, key)
+		problem := ""
+		if err != nil {
+			source = ""
+			// A key that is not set is only a problem if the lookup itself failed, like a missing provider profile
+			if !errors.Is(err, env.ErrNotFound) {
+				problem = err.Error()
+			}
+		} else {
+			values[key] = value
+			problem = validateConfig(key, value)
+		}
+		if problem != "" {
+			problems++
+			problem = "⚠️ " + problem
+		}
+		display := value
+		if slices.Contains(secretKeys, key) {
+			display = redact(value)
+		}
+		stdout += fmt.Sprintf("| %s | %s | %s | %s |\n", key, display, source, problem)
+	}
+
+	if (values["AWS_ACCESS_KEY_ID"] == "") != (values["AWS_SECRET_ACCESS_KEY"] == "") {
+		problems++
+		stdout += "\n⚠️ Both AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required to use static AWS credentials\n"
+	}
+	stdout += fmt.Sprintf("\n%d problem(s) found\n", problems)
+
+	msg := mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   task.Task.ID,
+		Response: []byte(stdout),
+	}
+
+	r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Failed to send response: %s", err.Error())
+		resp.Success = false
+		logging.LogError(err, "there was an error sending the Mythic RPC Response Create message")
+		return
+	}
+
+	if values[env.ProfileKey] != "" {
+		display := values[env.ProfileKey]
+		resp.DisplayParams = &display
+	}
+	resp.Success = true
+	resp.Completed = &r.Success
+	return
+}
+
+// validateConfig returns why the setting's value is not valid, or an empty string if it is
+func validateConfig(key, value string) string {
+	switch key {
+	case env.ProfileKey:
+		// Lookups skip a profile that can not be used, so this is the only place it is reported
+		if err := env.CheckProfile(value); err != nil {
+			return err.Error()
+		}
+	case "provider":
+		if !slices.Contains(env.ProvidersString(), strings.ToLower(value)) {
+			return fmt.Sprintf("unknown provider '%s', use one of: %s", value, strings.Join(env.ProvidersString(), ", "))
+		}
+	case "API_ENDPOINT":
+		u, err := url.Parse(value)
+		if err != nil {
+			return fmt.Sprintf("malformed URL: %s", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "the URL must start with http:// or https:// and include a host"
+		}
+	case "AWS_DEFAULT_REGION":
+		if !awsRegion.MatchString(value) {
+			return fmt.Sprintf("'%s' is not an AWS region name (e.g., us-east-1)", value)
+		}
+	case "AWS_ROLE_ARN":
+		if !strings.HasPrefix(value, "arn:aws") || !strings.Contains(value, ":role/") {
+			return "not an IAM role ARN (e.g., arn:aws:iam::123456789012:role/sage)"
+		}
+	case "AWS_CONFIG_FILE", "AWS_SHARED_CREDENTIALS_FILE", "AWS_WEB_IDENTITY_TOKEN_FILE":
+		if _, err := os.Stat(value); err != nil {
+			return fmt.Sprintf("the file can not be read in the container: %s", err)
+		}
+	}
+	return ""
+}
+
+// redact hides a secret value, keeping the last four characters of long values so they can be told apart
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) < 12 {
+		return "********"
+	}
+	return "********" + value[len(value)-4:]
+}
@@ -6,8 +6,10 @@ import (
 	"strings"
 
 	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/anthropic"
 	b "github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/bedrock"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/models"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/ollama"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/openai"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/openwebui"
@@ -150,18 +152,52 @@ func list() structs.Command {
 		},
 	}
 
+	filter := structs.CommandParameter{
+		Name:             "filter",
+		ModalDisplayName: "Filter",
+		CLIName:          "filter",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_STRING,
+		DefaultValue:     "",
+		Description:      "[OPTIONAL] Only list the models with this text in their ID or name, ignoring case (e.g., claude)",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       7,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	jsonOutput := structs.CommandParameter{
+		Name:             "json",
+		ModalDisplayName: "JSON Output",
+		CLIName:          "json",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_BOOLEAN,
+		DefaultValue:     false,
+		Description:      "[OPTIONAL] Return the models as a JSON array instead of a table",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       8,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
 	command := structs.Command{
 		Name:                           "list",
 		NeedsAdminPermissions:          false,
-		HelpString:                     "list",
-		Description:                    "List all available from the selected provider",
+		HelpString:                     "list -provider <provider> -filter <text> -json",
+		Description:                    "List the models available from the selected provider",
 		Version:                        0,
 		SupportedUIFeatures:            nil,
 		Author:                         "@Ne0nd0g",
 		MitreAttackMappings:            []string{},
 		ScriptOnlyCommand:              false,
 		CommandAttributes:              attr,
-		CommandParameters:              []structs.CommandParameter{provider, apiEndpoint, apiKey, awsAccessKey, awsSecretAccessKey, awsSessionToken, awsRegion},
+		CommandParameters:              []structs.CommandParameter{provider, apiEndpoint, apiKey, awsAccessKey, awsSecretAccessKey, awsSessionToken, awsRegion, filter, jsonOutput, profileParameter(9, "Default")},
 		AssociatedBrowserScript:        nil,
 		TaskFunctionOPSECPre:           nil,
 		TaskFunctionParseArgString:     taskFunctionParseArgString,
@@ -187,45 +223,44 @@ func listCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCrea
 		return
 	}
 
-	var stdout string
+	filter, err := task.Args.GetStringArg("filter")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'filter' argument: %s", err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
 
-	switch strings.ToLower(provider) {
-	case "bedrock":
-		bedrockModels, err := b.ListFoundationalModels(task)
-		if err != nil {
-			resp.Error = fmt.Sprintf("Failed to list Bedrock models: %s", err.Error())
-			resp.Success = false
-			logging.LogError(err, "there was an error listing bedrock models")
-			return
-		}
+	jsonOutput, err := task.Args.GetBooleanArg("json")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'json' argument: %s", err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
 
-		for _, m := range bedrockModels {
-			stdout += fmt.Sprintf("%s\n", m)
-		}
-	case "openai":
-		stdout, err = openai.List(task)
-		if err != nil {
-			resp.Error = fmt.Sprintf("Failed to list OpenAI models: %s", err.Error())
-			resp.Success = false
-			logging.LogError(err, "there was an error listing OpenAI models")
-			return
-		}
-	case "ollama":
-		stdout, err = ollama.List(task)
-		if err != nil {
-			resp.Error = fmt.Sprintf("Failed to list ollama models: %s", err.Error())
-			resp.Success = false
-			logging.LogError(err, "there was an error listing ollama models")
-			return
-		}
-	case "openwebui":
-		stdout, err = openwebui.List(task)
+	list, err := listModels(task, provider)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Failed to list %s models: %s", provider, err.Error())
+		resp.Success = false
+		logging.LogError(err, "there was an error listing models", "Provider", provider)
+		return
+	}
+	list = models.Filter(list, filter)
+
+	var stdout string
+	if jsonOutput {
+		stdout, err = models.JSON(list)
 		if err != nil {
-			resp.Error = fmt.Sprintf("Failed to list OpenWebUI models: %s", err.Error())
+			resp.Error = fmt.Sprintf("Failed to convert the models to JSON: %s", err.Error())
 			resp.Success = false
-			logging.LogError(err, "there was an error listing openwebui models")
+			logging.LogError(err, "there was an error converting the models to JSON")
 			return
 		}
+	} else {
+		stdout = fmt.Sprintf("%d %s model(s)\n\n%s", len(list), provider, models.Table(list))
 	}
 
 	msg := mythicrpc.MythicRPCResponseCreateMessage{
@@ -241,8 +276,30 @@ func listCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCrea
 		return
 	}
 
-	resp.DisplayParams = &provider
+	display := provider
+	if filter != "" {
+		display += fmt.Sprintf(" matching '%s'", filter)
+	}
+	resp.DisplayParams = &display
 	resp.Success = true
 	resp.Completed = &r.Success
 	return
 }
+
+// listModels returns the models offered by the provider using the endpoint and credentials resolved for the task
+func listModels(task *structs.PTTaskMessageAllData, provider string) ([]models.Model, error) {
+	switch strings.ToLower(provider) {
+	case "anthropic":
+		return anthropic.List(task)
+	case "bedrock":
+		return b.List(task)
+	case "openai":
+		return openai.List(task)
+	case "ollama":
+		return ollama.List(task)
+	case "openwebui":
+		return openwebui.List(task)
+	default:
+		return nil, fmt.Errorf("unknown provider '%s'", provider)
+	}
+}
@@ -0,0 +1,176 @@
+package commands
+
+import (
+	// Standard
+	"fmt"
+	"strconv"
+	"strings"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/message"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+func mcpAttach() structs.Command {
+	attr := structs.CommandAttribute{
+		SupportedOS: []string{"sage"},
+	}
+
+	uri := structs.CommandParameter{
+		Name:             "uri",
+		ModalDisplayName: "Resource URI",
+		CLIName:          "uri",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_STRING,
+		DefaultValue:     "",
+		Description:      "The URI of the resource to read; resource template URIs must be filled in (e.g., file:///etc/hosts)",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   true,
+				GroupName:             "Default",
+				UIModalPosition:       1,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	chatSession := structs.CommandParameter{
+		Name:                 "chat",
+		ModalDisplayName:     "Chat Session",
+		CLIName:              "chat",
+		ParameterType:        structs.COMMAND_PARAMETER_TYPE_CHOOSE_ONE,
+		Description:          "The task ID of the chat session to attach the resource to",
+		Choices:              []string{},
+		DefaultValue:         "",
+		DynamicQueryFunction: GetChatSessionList,
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   true,
+				GroupName:             "Default",
+				UIModalPosition:       2,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	command := structs.Command{
+		Name:                           "mcp-attach",
+		NeedsAdminPermissions:          false,
+		HelpString:                     "mcp-attach -id <id> -uri <uri> -chat <task id>",
+		Description:                    "Read a resource from an MCP server and attach it to a chat session as context for the model",
+		Version:                        0,
+		SupportedUIFeatures:            nil,
+		Author:                         "@Ne0nd0g",
+		MitreAttackMappings:            []string{},
+		ScriptOnlyCommand:              false,
+		CommandAttributes:              attr,
+		CommandParameters:              []structs.CommandParameter{mcpClientID(), uri, chatSession},
+		AssociatedBrowserScript:        nil,
+		TaskFunctionOPSECPre:           nil,
+		TaskFunctionParseArgString:     taskFunctionParseArgString,
+		TaskFunctionParseArgDictionary: taskFunctionParseArgDictionary,
+		TaskFunctionCreateTasking:      mcpAttachCreateTask,
+		TaskFunctionProcessResponse:    nil,
+		TaskFunctionOPSECPost:          nil,
+		TaskCompletionFunctions:        nil,
+	}
+
+	return command
+}
+
+func mcpAttachCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCreateTaskingMessageResponse) {
+	resp.TaskID = task.Task.ID
+
+	id, err := task.Args.GetChooseOneArg("id")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'id' argument: %s", err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	uri, err := task.Args.GetStringArg("uri")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'uri' argument: %s", err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	chatArg, err := task.Args.GetChooseOneArg("chat")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'chat' argument: %s", err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+	chatID, err := strconv.Atoi(strings.TrimSpace(chatArg))
+	if err != nil {
+		err = fmt.Errorf("'%s' is not a valid chat session task ID: %s", chatArg, err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+	if _, ok := sessions.Get(chatID); !ok {
+		err = fmt.Errorf("chat session %d was not found, it may have exited", chatID)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	result, err := mcp.ReadResource(id, uri)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	// The resource is added to the chat as a user message so the model has it for every following prompt
+	var contents []string
+	for _, c := range result.Contents {
+		contents = append(contents, mcp.ResourceText(task, c))
+	}
+	m := message.Message{
+		Role:    message.User,
+		Content: fmt.Sprintf("The following MCP resource was attached as context for this conversation:\n%s", strings.Join(contents, "\n")),
+	}
+	sessions.UpdateMessages(chatID, m)
+
+	// Let the operator know in the chat session that the resource was attached
+	_, err = mythicrpc.SendMythicRPCResponseCreate(mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   chatID,
+		Response: []byte(fmt.Sprintf("📎 Attached MCP resource %s from task %d\n👤> ", uri, task.Task.ID)),
+	})
+	if err != nil {
+		logging.LogError(err, "there was an error sending the attachment notice to the chat session", "chat", chatID)
+	}
+
+	msg := mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   task.Task.ID,
+		Response: []byte(fmt.Sprintf("📎 Attached MCP resource %s (%d content item(s)) to chat session %d\n", uri, len(result.Contents), chatID)),
+	}
+
+	r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Failed to send response: %s", err.Error())
+		resp.Success = false
+		logging.LogError(err, "there was an error sending the Mythic RPC Response Create message")
+		return
+	}
+
+	disp := fmt.Sprintf("%s to chat %d", uri, chatID)
+	resp.DisplayParams = &disp
+	resp.Success = true
+	resp.Completed = &r.Success
+	return
+}
@@ -3,8 +3,11 @@ package commands
 import (
 	// Standard
 	"fmt"
+	"strconv"
+	"strings"
 
 	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
 
 	// Mythic
@@ -18,12 +21,115 @@ func mcpConnect() structs.Command {
 		SupportedOS: []string{"sage"},
 	}
 
+	transport := structs.CommandParameter{
+		Name:             "transport",
+		ModalDisplayName: "Transport",
+		CLIName:          "transport",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_CHOOSE_ONE,
+		Description:      "The transport used to communicate with the MCP server: stdio (local process), sse (HTTP+SSE), or http (Streamable HTTP)",
+		Choices:          mcp.Transports(),
+		DefaultValue:     string(mcp.Stdio),
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       0,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	alias := structs.CommandParameter{
+		Name:             "alias",
+		ModalDisplayName: "Alias",
+		CLIName:          "alias",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_STRING,
+		DefaultValue:     "",
+		Description:      "[OPTIONAL] The prefix added to the name of every tool from this MCP server (e.g., bloodhound__search). Defaults to the server's name",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       8,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	maxConcurrency := structs.CommandParameter{
+		Name:             "max_concurrency",
+		ModalDisplayName: "Max Concurrent Tool Calls",
+		CLIName:          "max_concurrency",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_NUMBER,
+		DefaultValue:     mcp.DefaultMaxConcurrency,
+		Description:      "[OPTIONAL] The number of tool calls that run on this MCP server at the same time. Other calls wait for their turn",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       9,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	timeout := structs.CommandParameter{
+		Name:             "timeout",
+		ModalDisplayName: "Tool Call Timeout",
+		CLIName:          "timeout",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_NUMBER,
+		DefaultValue:     int(mcp.DefaultTimeout.Seconds()),
+		Description:      "[OPTIONAL] The number of seconds a tool call can run on this MCP server before it is reported to the model as failed",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       10,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	toolTimeouts := structs.CommandParameter{
+		Name:             "tool_timeouts",
+		ModalDisplayName: "Per-Tool Timeouts",
+		CLIName:          "tool_timeouts",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_ARRAY,
+		DefaultValue:     []string{},
+		Description:      "[OPTIONAL] Timeouts for individual tools that need more or less time in 'tool=seconds' format, using the tool's name on the MCP server without the alias (e.g., list_files=300)",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       11,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	maxResultBytes := structs.CommandParameter{
+		Name:             "max_result_bytes",
+		ModalDisplayName: "Max Tool Result Bytes",
+		CLIName:          "max_result_bytes",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_NUMBER,
+		DefaultValue:     mcp.DefaultMaxResultBytes,
+		Description:      "[OPTIONAL] The size of the largest tool result given to the model (about 4 bytes per token). Larger results are truncated and the full output is saved to Mythic as a file",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       12,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
 	mcpCommand := structs.CommandParameter{
 		Name:                                    "command",
 		ModalDisplayName:                        "command",
 		CLIName:                                 "command",
 		ParameterType:                           structs.COMMAND_PARAMETER_TYPE_STRING,
-		Description:                             "The command or program to start the MCP Server",
+		Description:                             "The command or program to start the MCP Server (stdio transport)",
 		DefaultValue:                            "",
 		SupportedAgents:                         nil,
 		SupportedAgentBuildParameters:           nil,
@@ -33,9 +139,9 @@ func mcpConnect() structs.Command {
 		DynamicQueryFunction:                    nil,
 		ParameterGroupInformation: []structs.ParameterGroupInfo{
 			{
-				ParameterIsRequired:   true,
+				ParameterIsRequired:   false,
 				GroupName:             "Default",
-				UIModalPosition:       0,
+				UIModalPosition:       1,
 				AdditionalInformation: nil,
 			},
 		},
@@ -47,12 +153,97 @@ func mcpConnect() structs.Command {
 		CLIName:          "args",
 		ParameterType:    structs.COMMAND_PARAMETER_TYPE_ARRAY,
 		DefaultValue:     []string{},
-		Description:      "Arguments to pass to the command",
+		Description:      "Arguments to pass to the command (stdio transport)",
 		ParameterGroupInformation: []structs.ParameterGroupInfo{
 			{
 				ParameterIsRequired:   false,
 				GroupName:             "Default",
-				UIModalPosition:       1,
+				UIModalPosition:       2,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	mcpEnv := structs.CommandParameter{
+		Name:             "env",
+		ModalDisplayName: "Environment Variables",
+		CLIName:          "env",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_ARRAY,
+		DefaultValue:     []string{},
+		Description:      "Environment variables for the command in KEY=value format (stdio transport). Use KEY alone, or ${NAME} in the value, to look the value up from the task, user secrets, build parameters, or container environment",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       3,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	mcpCwd := structs.CommandParameter{
+		Name:             "cwd",
+		ModalDisplayName: "Working Directory",
+		CLIName:          "cwd",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_STRING,
+		DefaultValue:     "",
+		Description:      "The working directory to start the command in (stdio transport)",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       4,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	mcpURL := structs.CommandParameter{
+		Name:             "url",
+		ModalDisplayName: "URL",
+		CLIName:          "url",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_STRING,
+		DefaultValue:     "",
+		Description:      "The URL of the remote MCP server endpoint (sse and http transports)",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       5,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	mcpHeaders := structs.CommandParameter{
+		Name:             "headers",
+		ModalDisplayName: "Headers",
+		CLIName:          "headers",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_ARRAY,
+		DefaultValue:     []string{},
+		Description:      "HTTP headers to send to the remote MCP server in 'Name: Value' format (sse and http transports)",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       6,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	mcpToken := structs.CommandParameter{
+		Name:             "MCP_BEARER_TOKEN",
+		ModalDisplayName: "MCP Bearer Token",
+		CLIName:          "MCP-BEARER-TOKEN",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_STRING,
+		DefaultValue:     "",
+		Description:      "[OPTIONAL] The bearer token sent in the Authorization header to the remote MCP server (sse and http transports)",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       7,
 				AdditionalInformation: nil,
 			},
 		},
@@ -61,15 +252,15 @@ func mcpConnect() structs.Command {
 	command := structs.Command{
 		Name:                           "mcp-connect",
 		NeedsAdminPermissions:          false,
-		HelpString:                     "mcp-connect -command <command> -args <args>",
-		Description:                    "Start and connect to a local Stdio MCP server",
+		HelpString:                     "mcp-connect -command <command> -args <args> -env <KEY=value> -cwd <dir> -alias <alias> | mcp-connect -transport <sse|http> -url <url> -headers <headers> -alias <alias>",
+		Description:                    "Start and connect to a local Stdio MCP server or connect to a remote SSE or Streamable HTTP MCP server",
 		Version:                        0,
 		SupportedUIFeatures:            nil,
 		Author:                         "@Ne0nd0g",
 		MitreAttackMappings:            []string{},
 		ScriptOnlyCommand:              false,
 		CommandAttributes:              attr,
-		CommandParameters:              []structs.CommandParameter{mcpCommand, mcpArgs},
+		CommandParameters:              []structs.CommandParameter{transport, mcpCommand, mcpArgs, mcpEnv, mcpCwd, mcpURL, mcpHeaders, mcpToken, alias, maxConcurrency, timeout, toolTimeouts, maxResultBytes, profileParameter(13, "Default")},
 		AssociatedBrowserScript:        nil,
 		TaskFunctionOPSECPre:           nil,
 		TaskFunctionParseArgString:     taskFunctionParseArgString,
@@ -86,25 +277,15 @@ func mcpConnect() structs.Command {
 func mcpConnectCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCreateTaskingMessageResponse) {
 	resp.TaskID = task.Task.ID
 
-	command, err := task.Args.GetStringArg("command")
-	if err != nil {
-		err = fmt.Errorf("there was an error getting the 'command' argument: %s", err)
-		resp.Error = err.Error()
-		resp.Success = false
-		logging.LogError(err, "returning with error")
-		return
-	}
-
-	args, err := task.Args.GetArrayArg("args")
+	server, err := mcpServerFromTask(task)
 	if err != nil {
-		err = fmt.Errorf("there was an error getting the 'args' argument: %s", err)
 		resp.Error = err.Error()
 		resp.Success = false
 		logging.LogError(err, "returning with error")
 		return
 	}
 
-	stdout, err := mcp.NewClient(command, args)
+	stdout, err := mcp.NewClient(server)
 	if err != nil {
 		err = fmt.Errorf("there was an error creating the MCP client: %s", err)
 		resp.Error = err.Error()
@@ -130,3 +311,140 @@ func mcpConnectCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTa
 	resp.Completed = &r.Success
 	return
 }
+
+// mcpServerFromTask builds the MCP server configuration from the mcp-connect task arguments
+func mcpServerFromTask(task *structs.PTTaskMessageAllData) (server mcp.Server, err error) {
+	transport, err := task.Args.GetChooseOneArg("transport")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'transport' argument: %s", err)
+		return
+	}
+	server.Transport = mcp.Transport(strings.ToLower(transport))
+
+	server.Alias, err = task.Args.GetStringArg("alias")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'alias' argument: %s", err)
+		return
+	}
+
+	maxConcurrency, err := task.Args.GetNumberArg("max_concurrency")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'max_concurrency' argument: %s", err)
+		return
+	}
+	if maxConcurrency < 0 {
+		err = fmt.Errorf("the 'max_concurrency' argument can not be negative")
+		return
+	}
+	server.MaxConcurrency = int(maxConcurrency)
+
+	timeout, err := task.Args.GetNumberArg("timeout")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'timeout' argument: %s", err)
+		return
+	}
+	if timeout < 0 {
+		err = fmt.Errorf("the 'timeout' argument can not be negative")
+		return
+	}
+	server.Timeout = int(timeout)
+
+	toolTimeouts, err := task.Args.GetArrayArg("tool_timeouts")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'tool_timeouts' argument: %s", err)
+		return
+	}
+	for _, t := range toolTimeouts {
+		tool, seconds, ok := strings.Cut(t, "=")
+		var s int
+		if ok {
+			s, err = strconv.Atoi(strings.TrimSpace(seconds))
+		}
+		if !ok || err != nil || s <= 0 {
+			err = fmt.Errorf("the tool timeout '%s' is not in 'tool=seconds' format", t)
+			return
+		}
+		if server.ToolTimeouts == nil {
+			server.ToolTimeouts = make(map[string]int)
+		}
+		server.ToolTimeouts[strings.TrimSpace(tool)] = s
+	}
+
+	maxResultBytes, err := task.Args.GetNumberArg("max_result_bytes")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'max_result_bytes' argument: %s", err)
+		return
+	}
+	if maxResultBytes < 0 {
+		err = fmt.Errorf("the 'max_result_bytes' argument can not be negative")
+		return
+	}
+	server.MaxResultBytes = int(maxResultBytes)
+
+	switch server.Transport {
+	case mcp.Stdio, "":
+		server.Transport = mcp.Stdio
+		server.Command, err = task.Args.GetStringArg("command")
+		if err != nil {
+			err = fmt.Errorf("there was an error getting the 'command' argument: %s", err)
+			return
+		}
+		server.Args, err = task.Args.GetArrayArg("args")
+		if err != nil {
+			err = fmt.Errorf("there was an error getting the 'args' argument: %s", err)
+			return
+		}
+		server.Dir, err = task.Args.GetStringArg("cwd")
+		if err != nil {
+			err = fmt.Errorf("there was an error getting the 'cwd' argument: %s", err)
+			return
+		}
+		var environment []string
+		environment, err = task.Args.GetArrayArg("env")
+		if err != nil {
+			err = fmt.Errorf("there was an error getting the 'env' argument: %s", err)
+			return
+		}
+		server.Env = make(map[string]string)
+		for _, e := range environment {
+			key, value, ok := strings.Cut(e, "=")
+			key = strings.TrimSpace(key)
+			// A key without a value is looked up the same way as the model provider settings
+			if !ok {
+				value = "${" + key + "}"
+			}
+			// The reference is kept, not its value, so secrets are not persisted to the state file
+			server.Env[key] = value
+		}
+	case mcp.SSE, mcp.StreamableHTTP:
+		server.URL, err = task.Args.GetStringArg("url")
+		if err != nil {
+			err = fmt.Errorf("there was an error getting the 'url' argument: %s", err)
+			return
+		}
+		var headers []string
+		headers, err = task.Args.GetArrayArg("headers")
+		if err != nil {
+			err = fmt.Errorf("there was an error getting the 'headers' argument: %s", err)
+			return
+		}
+		server.Headers = make(map[string]string)
+		for _, header := range headers {
+			name, value, ok := strings.Cut(header, ":")
+			if !ok {
+				err = fmt.Errorf("the header '%s' is not in 'Name: Value' format", header)
+				return
+			}
+			server.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+		// The token is optional and can come from the task, user secrets, build parameters, or the environment
+		if _, e := env.Get(task, "MCP_BEARER_TOKEN"); e == nil {
+			server.Headers["Authorization"] = "Bearer ${MCP_BEARER_TOKEN}"
+		}
+	default:
+		err = fmt.Errorf("unknown MCP transport '%s', expected one of: %s", transport, strings.Join(mcp.Transports(), ", "))
+		return
+	}
+	err = server.Resolve(task)
+	return
+}
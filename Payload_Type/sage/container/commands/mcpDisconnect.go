@@ -0,0 +1,123 @@
+package commands
+
+import (
+	// Standard
+	"errors"
+	"fmt"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+// mcpClientID returns the command parameter used to select a connected MCP client by its ID
+func mcpClientID() structs.CommandParameter {
+	return structs.CommandParameter{
+		Name:                                    "id",
+		ModalDisplayName:                        "MCP Client ID",
+		CLIName:                                 "id",
+		ParameterType:                           structs.COMMAND_PARAMETER_TYPE_CHOOSE_ONE,
+		Description:                             "The ID of the MCP client returned by mcp-connect",
+		Choices:                                 []string{},
+		DefaultValue:                            "",
+		SupportedAgents:                         nil,
+		SupportedAgentBuildParameters:           nil,
+		ChoicesAreAllCommands:                   false,
+		ChoicesAreLoadedCommands:                false,
+		FilterCommandChoicesByCommandAttributes: nil,
+		DynamicQueryFunction:                    GetMCPClientList,
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   true,
+				GroupName:             "Default",
+				UIModalPosition:       0,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+}
+
+// mcpServerID returns the parameter used to select a connected MCP client or a pending MCP server
+func mcpServerID() structs.CommandParameter {
+	id := mcpClientID()
+	id.Description = "The ID of the MCP client returned by mcp-connect, or of a server that could not be started when the container started"
+	id.DynamicQueryFunction = GetMCPServerList
+	return id
+}
+
+func mcpDisconnect() structs.Command {
+	attr := structs.CommandAttribute{
+		SupportedOS: []string{"sage"},
+	}
+
+	command := structs.Command{
+		Name:                           "mcp-disconnect",
+		NeedsAdminPermissions:          false,
+		HelpString:                     "mcp-disconnect -id <id>",
+		Description:                    "Disconnect from an MCP server and stop its process if it was started by Sage",
+		Version:                        0,
+		SupportedUIFeatures:            nil,
+		Author:                         "@Ne0nd0g",
+		MitreAttackMappings:            []string{},
+		ScriptOnlyCommand:              false,
+		CommandAttributes:              attr,
+		CommandParameters:              []structs.CommandParameter{mcpServerID()},
+		AssociatedBrowserScript:        nil,
+		TaskFunctionOPSECPre:           nil,
+		TaskFunctionParseArgString:     taskFunctionParseArgString,
+		TaskFunctionParseArgDictionary: taskFunctionParseArgDictionary,
+		TaskFunctionCreateTasking:      mcpDisconnectCreateTask,
+		TaskFunctionProcessResponse:    nil,
+		TaskFunctionOPSECPost:          nil,
+		TaskCompletionFunctions:        nil,
+	}
+
+	return command
+}
+
+func mcpDisconnectCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCreateTaskingMessageResponse) {
+	resp.TaskID = task.Task.ID
+
+	id, err := task.Args.GetChooseOneArg("id")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'id' argument: %s", err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	// The client is removed even if there was an error closing it
+	stdout := fmt.Sprintf("🔌 Disconnected MCP client %s\n", id)
+	err = mcp.Disconnect(id)
+	if errors.Is(err, mcp.ErrClientNotFound) {
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	} else if err != nil {
+		stdout += fmt.Sprintf("⚠️ %s\n", err)
+	}
+
+	msg := mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   task.Task.ID,
+		Response: []byte(stdout),
+	}
+
+	r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Failed to send response: %s", err.Error())
+		resp.Success = false
+		logging.LogError(err, "there was an error sending the Mythic RPC Response Create message")
+		return
+	}
+
+	resp.DisplayParams = &id
+	resp.Success = true
+	resp.Completed = &r.Success
+	return
+}
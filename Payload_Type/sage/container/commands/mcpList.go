@@ -0,0 +1,107 @@
+package commands
+
+import (
+	// Standard
+	"fmt"
+	"time"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+func mcpList() structs.Command {
+	attr := structs.CommandAttribute{
+		SupportedOS: []string{"sage"},
+	}
+
+	command := structs.Command{
+		Name:                           "mcp-list",
+		NeedsAdminPermissions:          false,
+		HelpString:                     "mcp-list",
+		Description:                    "List all connected MCP servers and their tools, and the servers that could not be started when the container started",
+		Version:                        0,
+		SupportedUIFeatures:            nil,
+		Author:                         "@Ne0nd0g",
+		MitreAttackMappings:            []string{},
+		ScriptOnlyCommand:              false,
+		CommandAttributes:              attr,
+		CommandParameters:              []structs.CommandParameter{},
+		AssociatedBrowserScript:        nil,
+		TaskFunctionOPSECPre:           nil,
+		TaskFunctionParseArgString:     taskFunctionParseArgString,
+		TaskFunctionParseArgDictionary: taskFunctionParseArgDictionary,
+		TaskFunctionCreateTasking:      mcpListCreateTask,
+		TaskFunctionProcessResponse:    nil,
+		TaskFunctionOPSECPost:          nil,
+		TaskCompletionFunctions:        nil,
+	}
+
+	return command
+}
+
+func mcpListCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCreateTaskingMessageResponse) {
+	resp.TaskID = task.Task.ID
+
+	clients := mcp.Clients()
+	stdout := fmt.Sprintf("🔌 %d MCP server(s) connected\n", len(clients))
+	for _, c := range clients {
+		stdout += "\n" + mcpClientString(c)
+	}
+	// Servers that could not be started when the container started wait for the operator to run mcp-restart
+	if servers := mcp.PendingServers(); len(servers) > 0 {
+		stdout += fmt.Sprintf("\n⏸️ %d MCP server(s) not started, start them with mcp-restart -id <id>\n", len(servers))
+		for _, p := range servers {
+			stdout += fmt.Sprintf("\nID: %s\n", p.ID)
+			if p.Server.Alias != "" {
+				stdout += fmt.Sprintf("Alias: %s\n", p.Server.Alias)
+			}
+			stdout += fmt.Sprintf("Transport: %s\n", p.Server.Transport)
+			stdout += fmt.Sprintf("Endpoint: %s\n", p.Server.Endpoint())
+			stdout += fmt.Sprintf("Error: %s\n", p.Error)
+		}
+	}
+
+	msg := mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   task.Task.ID,
+		Response: []byte(stdout),
+	}
+
+	r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Failed to send response: %s", err.Error())
+		resp.Success = false
+		logging.LogError(err, "there was an error sending the Mythic RPC Response Create message")
+		return
+	}
+
+	resp.Success = true
+	resp.Completed = &r.Success
+	return
+}
+
+// mcpClientString returns a human-readable description of the MCP client and its tools
+func mcpClientString(c mcp.MCPClient) (s string) {
+	s += fmt.Sprintf("ID: %s\n", c.ID)
+	s += fmt.Sprintf("Server: %s %s\n", c.Info.Name, c.Info.Version)
+	s += fmt.Sprintf("Alias: %s\n", c.Server.Alias)
+	s += fmt.Sprintf("Transport: %s\n", c.Server.Transport)
+	s += fmt.Sprintf("Endpoint: %s\n", c.Endpoint())
+	s += fmt.Sprintf("Connected: %s (%s ago)\n", c.Connected.Format(time.RFC3339), time.Since(c.Connected).Round(time.Second))
+	s += fmt.Sprintf("Limits: %d concurrent tool call(s), %s timeout, %d byte results\n", c.Server.Concurrency(), c.Server.CallTimeout(""), c.Server.ResultLimit())
+	if c.Tools != nil {
+		s += fmt.Sprintf("🛠️  Tools (%d):\n", len(c.Tools.Tools))
+		for _, tool := range c.Tools.Tools {
+			if timeout, ok := c.Server.ToolTimeouts[tool.Name]; ok {
+				s += fmt.Sprintf("- %s (%ds timeout)\n", c.ToolName(tool.Name), timeout)
+				continue
+			}
+			s += fmt.Sprintf("- %s\n", c.ToolName(tool.Name))
+		}
+	}
+	return
+}
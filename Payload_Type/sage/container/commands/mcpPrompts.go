@@ -0,0 +1,120 @@
+package commands
+
+import (
+	// Standard
+	"fmt"
+	"strings"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+func mcpPrompts() structs.Command {
+	attr := structs.CommandAttribute{
+		SupportedOS: []string{"sage"},
+	}
+
+	command := structs.Command{
+		Name:                           "mcp-prompts",
+		NeedsAdminPermissions:          false,
+		HelpString:                     "mcp-prompts -id <id>",
+		Description:                    "List the prompt templates an MCP server exposes and their arguments",
+		Version:                        0,
+		SupportedUIFeatures:            nil,
+		Author:                         "@Ne0nd0g",
+		MitreAttackMappings:            []string{},
+		ScriptOnlyCommand:              false,
+		CommandAttributes:              attr,
+		CommandParameters:              []structs.CommandParameter{mcpClientID()},
+		AssociatedBrowserScript:        nil,
+		TaskFunctionOPSECPre:           nil,
+		TaskFunctionParseArgString:     taskFunctionParseArgString,
+		TaskFunctionParseArgDictionary: taskFunctionParseArgDictionary,
+		TaskFunctionCreateTasking:      mcpPromptsCreateTask,
+		TaskFunctionProcessResponse:    nil,
+		TaskFunctionOPSECPost:          nil,
+		TaskCompletionFunctions:        nil,
+	}
+
+	return command
+}
+
+func mcpPromptsCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCreateTaskingMessageResponse) {
+	resp.TaskID = task.Task.ID
+
+	id, err := task.Args.GetChooseOneArg("id")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'id' argument: %s", err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	prompts, err := mcp.ListPrompts(id)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	stdout := fmt.Sprintf("💬 Prompts (%d):\n", len(prompts))
+	for _, p := range prompts {
+		stdout += fmt.Sprintf("- %s", p.Name)
+		if p.Description != "" {
+			stdout += fmt.Sprintf(": %s", p.Description)
+		}
+		stdout += "\n"
+		for _, arg := range p.Arguments {
+			required := ""
+			if arg.Required {
+				required = " (required)"
+			}
+			stdout += fmt.Sprintf("\t- %s%s: %s\n", arg.Name, required, arg.Description)
+		}
+	}
+
+	msg := mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   task.Task.ID,
+		Response: []byte(stdout),
+	}
+
+	r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Failed to send response: %s", err.Error())
+		resp.Success = false
+		logging.LogError(err, "there was an error sending the Mythic RPC Response Create message")
+		return
+	}
+
+	resp.DisplayParams = &id
+	resp.Success = true
+	resp.Completed = &r.Success
+	return
+}
+
+// promptArguments parses the 'mcp_prompt_arguments' task argument entries in the NAME=VALUE format
+func promptArguments(task *structs.PTTaskMessageAllData) (args map[string]string, err error) {
+	entries, err := task.Args.GetArrayArg("mcp_prompt_arguments")
+	if err != nil {
+		return nil, fmt.Errorf("there was an error getting the 'mcp_prompt_arguments' argument: %s", err)
+	}
+	args = make(map[string]string)
+	for _, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("the MCP prompt argument '%s' is not in the NAME=VALUE format", entry)
+		}
+		args[strings.TrimSpace(name)] = value
+	}
+	return
+}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	// Standard
+	"fmt"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+func mcpResources() structs.Command {
+	attr := structs.CommandAttribute{
+		SupportedOS: []string{"sage"},
+	}
+
+	command := structs.Command{
+		Name:                           "mcp-resources",
+		NeedsAdminPermissions:          false,
+		HelpString:                     "mcp-resources -id <id>",
+		Description:                    "List the resources and resource templates an MCP server exposes",
+		Version:                        0,
+		SupportedUIFeatures:            nil,
+		Author:                         "@Ne0nd0g",
+		MitreAttackMappings:            []string{},
+		ScriptOnlyCommand:              false,
+		CommandAttributes:              attr,
+		CommandParameters:              []structs.CommandParameter{mcpClientID()},
+		AssociatedBrowserScript:        nil,
+		TaskFunctionOPSECPre:           nil,
+		TaskFunctionParseArgString:     taskFunctionParseArgString,
+		TaskFunctionParseArgDictionary: taskFunctionParseArgDictionary,
+		TaskFunctionCreateTasking:      mcpResourcesCreateTask,
+		TaskFunctionProcessResponse:    nil,
+		TaskFunctionOPSECPost:          nil,
+		TaskCompletionFunctions:        nil,
+	}
+
+	return command
+}
+
+func mcpResourcesCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCreateTaskingMessageResponse) {
+	resp.TaskID = task.Task.ID
+
+	id, err := task.Args.GetChooseOneArg("id")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'id' argument: %s", err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	resources, err := mcp.ListResources(id)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	stdout := fmt.Sprintf("📚 Resources (%d):\n", len(resources.Resources))
+	for _, r := range resources.Resources {
+		stdout += fmt.Sprintf("- %s (%s) %s", r.URI, r.Name, r.MIMEType)
+		if r.Description != "" {
+			stdout += fmt.Sprintf(": %s", r.Description)
+		}
+		stdout += "\n"
+	}
+	stdout += fmt.Sprintf("\n📐 Resource Templates (%d):\n", len(resources.Templates))
+	for _, t := range resources.Templates {
+		var uri string
+		if t.URITemplate != nil && t.URITemplate.Template != nil {
+			uri = t.URITemplate.Raw()
+		}
+		stdout += fmt.Sprintf("- %s (%s) %s", uri, t.Name, t.MIMEType)
+		if t.Description != "" {
+			stdout += fmt.Sprintf(": %s", t.Description)
+		}
+		stdout += "\n"
+	}
+
+	msg := mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   task.Task.ID,
+		Response: []byte(stdout),
+	}
+
+	r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Failed to send response: %s", err.Error())
+		resp.Success = false
+		logging.LogError(err, "there was an error sending the Mythic RPC Response Create message")
+		return
+	}
+
+	resp.DisplayParams = &id
+	resp.Success = true
+	resp.Completed = &r.Success
+	return
+}
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	// Standard
+	"fmt"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+func mcpRestart() structs.Command {
+	attr := structs.CommandAttribute{
+		SupportedOS: []string{"sage"},
+	}
+
+	// The alias can be typed instead of picking an ID
+	id := mcpServerID()
+	id.ParameterType = structs.COMMAND_PARAMETER_TYPE_CHOOSE_ONE_CUSTOM
+	id.Description = "The ID, or alias, of the MCP client returned by mcp-connect, or of a server that could not be started when the container started"
+
+	command := structs.Command{
+		Name:                           "mcp-restart",
+		NeedsAdminPermissions:          false,
+		HelpString:                     "mcp-restart -id <id or alias>",
+		Description:                    "Restart or reconnect to an MCP server with its original configuration, resolving its ${KEY} references for this task, and refresh its tools. Also starts servers that could not be started when the container started",
+		Version:                        0,
+		SupportedUIFeatures:            nil,
+		Author:                         "@Ne0nd0g",
+		MitreAttackMappings:            []string{},
+		ScriptOnlyCommand:              false,
+		CommandAttributes:              attr,
+		CommandParameters:              []structs.CommandParameter{id},
+		AssociatedBrowserScript:        nil,
+		TaskFunctionOPSECPre:           nil,
+		TaskFunctionParseArgString:     taskFunctionParseArgString,
+		TaskFunctionParseArgDictionary: taskFunctionParseArgDictionary,
+		TaskFunctionCreateTasking:      mcpRestartCreateTask,
+		TaskFunctionProcessResponse:    nil,
+		TaskFunctionOPSECPost:          nil,
+		TaskCompletionFunctions:        nil,
+	}
+
+	return command
+}
+
+func mcpRestartCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCreateTaskingMessageResponse) {
+	resp.TaskID = task.Task.ID
+
+	id, err := task.Args.GetChooseOneArg("id")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'id' argument: %s", err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	stdout, err := mcp.Restart(task, id)
+	if err != nil {
+		err = fmt.Errorf("there was an error restarting the MCP client: %s", err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	msg := mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   task.Task.ID,
+		Response: []byte(stdout),
+	}
+
+	r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Failed to send response: %s", err.Error())
+		resp.Success = false
+		logging.LogError(err, "there was an error sending the Mythic RPC Response Create message")
+		return
+	}
+
+	resp.DisplayParams = &id
+	resp.Success = true
+	resp.Completed = &r.Success
+	return
+}
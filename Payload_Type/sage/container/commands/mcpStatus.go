@@ -0,0 +1,76 @@
+package commands
+
+import (
+	// Standard
+	"fmt"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+func mcpStatus() structs.Command {
+	attr := structs.CommandAttribute{
+		SupportedOS: []string{"sage"},
+	}
+
+	command := structs.Command{
+		Name:                           "mcp-status",
+		NeedsAdminPermissions:          false,
+		HelpString:                     "mcp-status",
+		Description:                    "Ping all connected MCP servers, refresh their tools, and remove servers that do not respond",
+		Version:                        0,
+		SupportedUIFeatures:            nil,
+		Author:                         "@Ne0nd0g",
+		MitreAttackMappings:            []string{},
+		ScriptOnlyCommand:              false,
+		CommandAttributes:              attr,
+		CommandParameters:              []structs.CommandParameter{},
+		AssociatedBrowserScript:        nil,
+		TaskFunctionOPSECPre:           nil,
+		TaskFunctionParseArgString:     taskFunctionParseArgString,
+		TaskFunctionParseArgDictionary: taskFunctionParseArgDictionary,
+		TaskFunctionCreateTasking:      mcpStatusCreateTask,
+		TaskFunctionProcessResponse:    nil,
+		TaskFunctionOPSECPost:          nil,
+		TaskCompletionFunctions:        nil,
+	}
+
+	return command
+}
+
+func mcpStatusCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCreateTaskingMessageResponse) {
+	resp.TaskID = task.Task.ID
+
+	statuses := mcp.CheckStatus()
+	stdout := fmt.Sprintf("🩺 Checked %d MCP server(s)\n", len(statuses))
+	for _, status := range statuses {
+		if status.Alive {
+			stdout += fmt.Sprintf("\n✅ %s %s is healthy\n", status.Client.ID, status.Client.Info.Name)
+			stdout += mcpClientString(status.Client)
+		} else {
+			stdout += fmt.Sprintf("\n❌ %s %s did not respond and was removed: %s\n", status.Client.ID, status.Client.Info.Name, status.Error)
+		}
+	}
+
+	msg := mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   task.Task.ID,
+		Response: []byte(stdout),
+	}
+
+	r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Failed to send response: %s", err.Error())
+		resp.Success = false
+		logging.LogError(err, "there was an error sending the Mythic RPC Response Create message")
+		return
+	}
+
+	resp.Success = true
+	resp.Completed = &r.Success
+	return
+}
@@ -7,9 +7,14 @@ import (
 
 	// Internal
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/anthropic"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/bedrock"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/credentials"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/message"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mythic"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/openai"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/schema"
 
 	// Mythic
 	structs "github.com/MythicMeta/MythicContainer/agent_structs"
@@ -49,6 +54,12 @@ func query() structs.Command {
 				UIModalPosition:       0,
 				AdditionalInformation: nil,
 			},
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "MCP Prompt",
+				UIModalPosition:       0,
+				AdditionalInformation: nil,
+			},
 		},
 	}
 
@@ -56,15 +67,15 @@ func query() structs.Command {
 		Name:                                    "model",
 		ModalDisplayName:                        "model",
 		CLIName:                                 "model",
-		ParameterType:                           structs.COMMAND_PARAMETER_TYPE_STRING,
+		ParameterType:                           structs.COMMAND_PARAMETER_TYPE_CHOOSE_ONE_CUSTOM,
 		DefaultValue:                            "",
-		Description:                             "The model to use for inference from the selected provider",
+		Description:                             "The model to use for inference from the selected provider. Choose one of the provider's models or enter a model ID",
 		SupportedAgents:                         nil,
 		SupportedAgentBuildParameters:           nil,
 		ChoicesAreAllCommands:                   false,
 		ChoicesAreLoadedCommands:                false,
 		FilterCommandChoicesByCommandAttributes: nil,
-		DynamicQueryFunction:                    nil,
+		DynamicQueryFunction:                    GetModelList,
 		ParameterGroupInformation: []structs.ParameterGroupInfo{
 			{
 				ParameterIsRequired:   false,
@@ -78,6 +89,12 @@ func query() structs.Command {
 				UIModalPosition:       1,
 				AdditionalInformation: nil,
 			},
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "MCP Prompt",
+				UIModalPosition:       1,
+				AdditionalInformation: nil,
+			},
 		},
 	}
 
@@ -130,6 +147,12 @@ func query() structs.Command {
 				UIModalPosition:       3,
 				AdditionalInformation: nil,
 			},
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "MCP Prompt",
+				UIModalPosition:       3,
+				AdditionalInformation: nil,
+			},
 		},
 	}
 
@@ -153,6 +176,12 @@ func query() structs.Command {
 				UIModalPosition:       4,
 				AdditionalInformation: nil,
 			},
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "MCP Prompt",
+				UIModalPosition:       4,
+				AdditionalInformation: nil,
+			},
 		},
 	}
 
@@ -176,6 +205,12 @@ func query() structs.Command {
 				UIModalPosition:       5,
 				AdditionalInformation: nil,
 			},
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "MCP Prompt",
+				UIModalPosition:       5,
+				AdditionalInformation: nil,
+			},
 		},
 	}
 
@@ -199,6 +234,12 @@ func query() structs.Command {
 				UIModalPosition:       6,
 				AdditionalInformation: nil,
 			},
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "MCP Prompt",
+				UIModalPosition:       6,
+				AdditionalInformation: nil,
+			},
 		},
 	}
 
@@ -222,6 +263,12 @@ func query() structs.Command {
 				UIModalPosition:       7,
 				AdditionalInformation: nil,
 			},
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "MCP Prompt",
+				UIModalPosition:       7,
+				AdditionalInformation: nil,
+			},
 		},
 	}
 
@@ -245,6 +292,12 @@ func query() structs.Command {
 				UIModalPosition:       8,
 				AdditionalInformation: nil,
 			},
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "MCP Prompt",
+				UIModalPosition:       8,
+				AdditionalInformation: nil,
+			},
 		},
 	}
 	awsSessionToken := structs.CommandParameter{
@@ -267,6 +320,12 @@ func query() structs.Command {
 				UIModalPosition:       9,
 				AdditionalInformation: nil,
 			},
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "MCP Prompt",
+				UIModalPosition:       9,
+				AdditionalInformation: nil,
+			},
 		},
 	}
 	awsRegion := structs.CommandParameter{
@@ -289,6 +348,12 @@ func query() structs.Command {
 				UIModalPosition:       10,
 				AdditionalInformation: nil,
 			},
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "MCP Prompt",
+				UIModalPosition:       10,
+				AdditionalInformation: nil,
+			},
 		},
 	}
 
@@ -340,6 +405,78 @@ func query() structs.Command {
 		},
 	}
 
+	mcpPrompt := structs.CommandParameter{
+		Name:                 "mcp_prompt",
+		ModalDisplayName:     "MCP Prompt",
+		CLIName:              "mcp-prompt",
+		ParameterType:        structs.COMMAND_PARAMETER_TYPE_CHOOSE_ONE,
+		Description:          "The MCP server prompt template, prefixed with the server's alias, to send to the model",
+		Choices:              []string{},
+		DefaultValue:         "",
+		DynamicQueryFunction: GetMCPPromptList,
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   true,
+				GroupName:             "MCP Prompt",
+				UIModalPosition:       2,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	mcpPromptArguments := structs.CommandParameter{
+		Name:             "mcp_prompt_arguments",
+		ModalDisplayName: "MCP Prompt Arguments",
+		CLIName:          "mcp-prompt-arguments",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_ARRAY,
+		DefaultValue:     []string{},
+		Description:      "[OPTIONAL] The arguments for the MCP prompt template in the NAME=VALUE format",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "MCP Prompt",
+				UIModalPosition:       11,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	toolsAllow, toolsDeny, toolsConfirm := toolFilterParameters(12, "Default", "New File", "MCP Prompt")
+	record := recordParameter(17, "Default", "New File", "MCP Prompt")
+	profile := profileParameter(20, "Default", "New File", "MCP Prompt")
+	mythicTools, mythicSecrets := mythicToolParameters(21, "Default", "New File", "MCP Prompt")
+
+	extractCredentials := structs.CommandParameter{
+		Name:             credentials.Arg,
+		ModalDisplayName: "Extract Credentials",
+		CLIName:          "extract-credentials",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_BOOLEAN,
+		DefaultValue:     false,
+		Description:      "[OPTIONAL] Have the model extract the credentials from the prompt (e.g., mimikatz or secretsdump output) as validated JSON. Review them and add them to Mythic with the save-credentials command",
+	}
+
+	responseSchema := structs.CommandParameter{
+		Name:             schema.Arg,
+		ModalDisplayName: "Response Schema",
+		CLIName:          "schema",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_STRING,
+		DefaultValue:     "",
+		Description:      fmt.Sprintf("[OPTIONAL] A JSON schema, or the name of a built-in schema (%s), the model's answer must follow. The task response is the validated, pretty-printed JSON", strings.Join(schema.Names(), ", ")),
+	}
+
+	for _, group := range []string{"Default", "New File", "MCP Prompt"} {
+		extractCredentials.ParameterGroupInformation = append(extractCredentials.ParameterGroupInformation, structs.ParameterGroupInfo{
+			ParameterIsRequired: false,
+			GroupName:           group,
+			UIModalPosition:     18,
+		})
+		responseSchema.ParameterGroupInformation = append(responseSchema.ParameterGroupInformation, structs.ParameterGroupInfo{
+			ParameterIsRequired: false,
+			GroupName:           group,
+			UIModalPosition:     19,
+		})
+	}
+
 	command := structs.Command{
 		Name:                           "query",
 		NeedsAdminPermissions:          false,
@@ -351,8 +488,8 @@ func query() structs.Command {
 		MitreAttackMappings:            []string{},
 		ScriptOnlyCommand:              false,
 		CommandAttributes:              attr,
-		CommandParameters:              []structs.CommandParameter{provider, model, prompt, tools, verbose, apiEndpoint, apiKey, awsAccessKey, awsSecretAccessKey, awsSessionToken, awsRegion, file, filename},
-		AssociatedBrowserScript:        nil,
+		CommandParameters:              []structs.CommandParameter{provider, model, prompt, tools, verbose, apiEndpoint, apiKey, awsAccessKey, awsSecretAccessKey, awsSessionToken, awsRegion, file, filename, mcpPrompt, mcpPromptArguments, toolsAllow, toolsDeny, toolsConfirm, record, extractCredentials, responseSchema, profile, mythicTools, mythicSecrets},
+		AssociatedBrowserScript:        transcriptBrowserScript(),
 		TaskFunctionOPSECPre:           nil,
 		TaskFunctionCreateTasking:      queryCreateTask,
 		TaskFunctionProcessResponse:    nil,
@@ -393,15 +530,6 @@ func queryCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCre
 		return
 	}
 
-	prompt, err := task.Args.GetStringArg("prompt")
-	if err != nil {
-		err = fmt.Errorf("%s: there was an error getting the 'prompt' argument: %s", pkg, err)
-		resp.Error = err.Error()
-		resp.Success = false
-		logging.LogError(err, "returning with error")
-		return
-	}
-
 	tools, err := task.Args.GetBooleanArg("tools")
 	if err != nil {
 		err = fmt.Errorf("%s: there was an error getting the 'tools' argument: %s", pkg, err)
@@ -421,62 +549,114 @@ func queryCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCre
 	}
 
 	var output []message.Message
+	var msgs []message.Message
 
-	respMsg := mythicrpc.MythicRPCResponseCreateMessage{
-		TaskID:   task.Task.ID,
-		Response: []byte(fmt.Sprintf("👤> %s\n", prompt)),
+	group, _ := task.Args.GetParameterGroupName()
+	if group == "MCP Prompt" {
+		// Start the query from a prompt template provided by an MCP server
+		msgs, err = getMCPPrompt(task)
+		if err != nil {
+			err = fmt.Errorf("%s: %s", pkg, err)
+			resp.Error = err.Error()
+			resp.Success = false
+			logging.LogError(err, "returning with error")
+			return
+		}
+	} else {
+		prompt, err := task.Args.GetStringArg("prompt")
+		if err != nil {
+			err = fmt.Errorf("%s: there was an error getting the 'prompt' argument: %s", pkg, err)
+			resp.Error = err.Error()
+			resp.Success = false
+			logging.LogError(err, "returning with error")
+			return
+		}
+		msgs = []message.Message{{Role: message.User, Content: prompt}}
 	}
 
-	_, err = mythicrpc.SendMythicRPCResponseCreate(respMsg)
+	// Credential extraction constrains the answer to the built-in credentials schema
+	extract := credentials.Enabled(task)
+	if extract {
+		userSchema, _ := task.Args.GetStringArg(schema.Arg)
+		if s := strings.TrimSpace(userSchema); s != "" && s != credentials.Schema().Name {
+			err = fmt.Errorf("%s: the '%s' and '%s' arguments can not be used together because credential extraction uses the built-in %s schema", pkg, credentials.Arg, schema.Arg, credentials.Schema().Name)
+			resp.Error = err.Error()
+			resp.Success = false
+			logging.LogError(err, "returning with error")
+			return
+		}
+		task.Args.SetArgValue(schema.Arg, credentials.Schema().Name)
+	}
+	responseSchema, err := schema.FromTask(task)
 	if err != nil {
-		resp.Error = fmt.Sprintf("Failed to send response: %s", err.Error())
+		resp.Error = err.Error()
 		resp.Success = false
-		logging.LogError(err, pkg)
+		logging.LogError(err, "returning with error")
 		return
 	}
+	// Scripts consume the JSON answer, so it is the only task output unless verbose output was requested
+	jsonOnly := responseSchema != nil && !extract && !verbose
 
-	msg := message.Message{
-		Role:    message.User,
-		Content: prompt,
+	var input []byte
+	for _, m := range msgs {
+		input = append(input, message.Response(m)...)
 	}
-	switch strings.ToLower(provider) {
-	case "anthropic":
-		output, err = anthropic.Chat(task, []message.Message{msg}, tools, verbose)
+	respMsg := mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   task.Task.ID,
+		Response: input,
+	}
+
+	if !jsonOnly {
+		_, err = mythicrpc.SendMythicRPCResponseCreate(respMsg)
 		if err != nil {
-			resp.Error = fmt.Sprintf("Failed to invoke model: %s", err.Error())
+			resp.Error = fmt.Sprintf("Failed to send response: %s", err.Error())
 			resp.Success = false
 			logging.LogError(err, pkg)
 			return
 		}
-	case "bedrock":
-		if !strings.Contains(model, ".anthropic.") {
-			err = fmt.Errorf("model '%s' is not supported by Bedrock", model)
-			resp.Error = err.Error()
+	}
+
+	output, err = invoke(task, provider, model, msgs, tools, verbose)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, pkg)
+		return
+	}
+
+	if responseSchema != nil {
+		output, err = structuredOutput(task, provider, model, *responseSchema, msgs, output, tools, verbose)
+		if err != nil {
+			resp.Error = fmt.Sprintf("Failed to get a valid JSON answer: %s", err.Error())
 			resp.Success = false
 			logging.LogError(err, pkg)
 			return
 		}
-		output, err = anthropic.Chat(task, []message.Message{msg}, tools, verbose)
+	}
+
+	if extract {
+		var creds []credentials.Credential
+		creds, err = credentials.Parse(output[len(output)-1].Content)
 		if err != nil {
-			resp.Error = fmt.Sprintf("Failed to invoke model: %s", err.Error())
+			resp.Error = fmt.Sprintf("Failed to extract credentials: %s", err.Error())
 			resp.Success = false
 			logging.LogError(err, pkg)
 			return
 		}
-	case "openai":
-		output, err = openai.Chat(task, []message.Message{msg}, tools, verbose)
+		err = credentials.Stage(task.Task.ID, creds)
 		if err != nil {
-			resp.Error = fmt.Sprintf("Failed to invoke model: %s", err.Error())
+			resp.Error = err.Error()
 			resp.Success = false
 			logging.LogError(err, pkg)
 			return
 		}
-	default:
-		resp.Error = fmt.Sprintf("Unknown provider: %s", provider)
-		resp.Success = false
-		logging.LogError(fmt.Errorf("unknown provider: %s", provider), pkg)
-		return
+		output = append(output, message.Message{
+			Role: message.Assistant,
+			Content: fmt.Sprintf("🔑 Extracted %d credential(s). Review them and run 'save-credentials -task <this task's number>' to add them to Mythic\n%s",
+				len(creds), credentials.Table(creds)),
+		})
 	}
+	mythic.Record(task, provider, model, output)
 
 	// Add the user's prompt to the output
 	// Store the assistant message in the session and send the response to the user
@@ -488,10 +668,9 @@ func queryCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCre
 		sessions.UpdateMessages(resp.TaskID, m)
 
 		if verbose {
-			x := fmt.Sprintf("🤖> %s\n", o.Content)
 			msg := mythicrpc.MythicRPCResponseCreateMessage{
 				TaskID:   resp.TaskID,
-				Response: []byte(x),
+				Response: message.Response(o),
 			}
 
 			r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
@@ -502,9 +681,13 @@ func queryCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCre
 				return
 			}
 		} else if k == len(output)-1 {
+			x := message.Response(o)
+			if jsonOnly {
+				x = []byte(o.Content)
+			}
 			msg := mythicrpc.MythicRPCResponseCreateMessage{
 				TaskID:   resp.TaskID,
-				Response: []byte(fmt.Sprintf("🤖> %s\n", o.Content)),
+				Response: x,
 			}
 
 			r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
@@ -525,3 +708,77 @@ func queryCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCre
 
 	return
 }
+
+// invoke sends the messages to the provider's model and returns its response messages
+func invoke(task *structs.PTTaskMessageAllData, provider, model string, msgs []message.Message, tools, verbose bool) (output []message.Message, err error) {
+	switch strings.ToLower(provider) {
+	case "anthropic":
+		output, err = anthropic.Chat(task, msgs, tools, verbose)
+	case "bedrock":
+		if _, err = bedrock.ValidateModel(task, model); err != nil {
+			return nil, err
+		}
+		output, err = anthropic.Chat(task, msgs, tools, verbose)
+	case "openai":
+		output, err = openai.Chat(task, msgs, tools, verbose)
+	default:
+		return nil, fmt.Errorf("Unknown provider: %s", provider)
+	}
+	if err != nil {
+		err = fmt.Errorf("Failed to invoke model: %s", err.Error())
+	}
+	return
+}
+
+// structuredOutput validates the model's answer against the schema and, when it is invalid, tells the model what is wrong
+// and asks again up to schema.MaxAttempts times. The answer is replaced with the pretty-printed JSON and every attempt
+// is returned in the output so verbose mode shows the exchange.
+func structuredOutput(task *structs.PTTaskMessageAllData, provider, model string, s schema.Schema, msgs, output []message.Message, tools, verbose bool) ([]message.Message, error) {
+	for attempt := 1; ; attempt++ {
+		if len(output) == 0 {
+			return output, fmt.Errorf("the model did not answer")
+		}
+		answer := output[len(output)-1].Content
+		pretty, err := s.Validate(answer)
+		if err == nil {
+			output[len(output)-1].Content = pretty
+			return output, nil
+		}
+		if attempt >= schema.MaxAttempts {
+			return output, fmt.Errorf("the model did not return a valid answer after %d attempts: %w", attempt, err)
+		}
+		logging.LogDebug("the model's answer does not follow the schema", "attempt", attempt, "error", err)
+
+		msgs = append(msgs,
+			message.Message{Role: message.Assistant, Content: answer},
+			message.Message{Role: message.User, Content: fmt.Sprintf("Your answer is not valid: %s\n%s", err, s.Instructions())},
+		)
+		var retry []message.Message
+		retry, err = invoke(task, provider, model, msgs, tools, verbose)
+		if err != nil {
+			return output, err
+		}
+		output = append(output, retry...)
+	}
+}
+
+// getMCPPrompt renders the MCP prompt template selected in the 'mcp_prompt' argument with its arguments
+func getMCPPrompt(task *structs.PTTaskMessageAllData) (msgs []message.Message, err error) {
+	name, err := task.Args.GetChooseOneArg("mcp_prompt")
+	if err != nil {
+		return nil, fmt.Errorf("there was an error getting the 'mcp_prompt' argument: %s", err)
+	}
+	args, err := promptArguments(task)
+	if err != nil {
+		return
+	}
+	result, err := mcp.GetPrompt(name, args)
+	if err != nil {
+		return
+	}
+	msgs = mcp.PromptMessages(task, result)
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("the MCP prompt %s did not return any messages", name)
+	}
+	return
+}
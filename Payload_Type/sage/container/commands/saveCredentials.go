@@ -0,0 +1,193 @@
+package commands
+
+import (
+	// Standard
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/credentials"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+func saveCredentials() structs.Command {
+	attr := structs.CommandAttribute{
+		SupportedOS: []string{"sage"},
+	}
+
+	taskID := structs.CommandParameter{
+		Name:             "task",
+		ModalDisplayName: "Task",
+		CLIName:          "task",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_NUMBER,
+		Description:      "The number of the query task that extracted the credentials",
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   true,
+				GroupName:             "Default",
+				UIModalPosition:       0,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	exclude := structs.CommandParameter{
+		Name:             "exclude",
+		ModalDisplayName: "Exclude",
+		CLIName:          "exclude",
+		ParameterType:    structs.COMMAND_PARAMETER_TYPE_ARRAY,
+		Description:      "[OPTIONAL] The row numbers (#) of the extracted credentials that should not be saved",
+		DefaultValue:     []string{},
+		ParameterGroupInformation: []structs.ParameterGroupInfo{
+			{
+				ParameterIsRequired:   false,
+				GroupName:             "Default",
+				UIModalPosition:       1,
+				AdditionalInformation: nil,
+			},
+		},
+	}
+
+	command := structs.Command{
+		Name:                           "save-credentials",
+		NeedsAdminPermissions:          false,
+		HelpString:                     "save-credentials -task <task number>",
+		Description:                    "Add the credentials a query extracted with extract_credentials to the Mythic credential store",
+		Version:                        0,
+		SupportedUIFeatures:            nil,
+		Author:                         "@Ne0nd0g",
+		MitreAttackMappings:            []string{},
+		ScriptOnlyCommand:              false,
+		CommandAttributes:              attr,
+		CommandParameters:              []structs.CommandParameter{taskID, exclude},
+		AssociatedBrowserScript:        nil,
+		TaskFunctionOPSECPre:           nil,
+		TaskFunctionParseArgString:     taskFunctionParseArgString,
+		TaskFunctionParseArgDictionary: taskFunctionParseArgDictionary,
+		TaskFunctionCreateTasking:      saveCredentialsCreateTask,
+		TaskFunctionProcessResponse:    nil,
+		TaskFunctionOPSECPost:          nil,
+		TaskCompletionFunctions:        nil,
+	}
+
+	return command
+}
+
+func saveCredentialsCreateTask(task *structs.PTTaskMessageAllData) (resp structs.PTTaskCreateTaskingMessageResponse) {
+	resp.TaskID = task.Task.ID
+
+	displayID, err := task.Args.GetNumberArg("task")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'task' argument: %s", err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	exclude, err := task.Args.GetArrayArg("exclude")
+	if err != nil {
+		err = fmt.Errorf("there was an error getting the 'exclude' argument: %s", err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	lookup, err := mythicrpc.SendMythicRPCTaskDisplayToRealIdSearch(mythicrpc.MythicRPCTaskDisplayToRealIdSearchMessage{
+		TaskDisplayID: int(displayID),
+		OperationID:   &task.Callback.OperationID,
+	})
+	if err != nil || !lookup.Success {
+		if err == nil {
+			err = fmt.Errorf("%s", lookup.Error)
+		}
+		err = fmt.Errorf("there was an error finding task %d: %s", int(displayID), err)
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	staged, err := credentials.Staged(lookup.TaskID)
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	var creds, existing []credentials.Credential
+	for i, c := range staged {
+		if slices.ContainsFunc(exclude, func(e string) bool { return strings.TrimSpace(e) == strconv.Itoa(i) }) {
+			continue
+		}
+		// Credentials saved by an earlier save-credentials task, or found some other way, are not added twice
+		found, err := credentials.Exists(task.Task.ID, c)
+		if err != nil {
+			resp.Error = err.Error()
+			resp.Success = false
+			logging.LogError(err, "returning with error")
+			return
+		}
+		if found {
+			existing = append(existing, c)
+			continue
+		}
+		creds = append(creds, c)
+	}
+	if len(creds) == 0 && len(existing) == 0 {
+		err = fmt.Errorf("every credential from task %d was excluded", int(displayID))
+		resp.Error = err.Error()
+		resp.Success = false
+		logging.LogError(err, "returning with error")
+		return
+	}
+
+	stdout := fmt.Sprintf("🔑 Saved %d of %d credential(s) from task %d to Mythic\n", len(creds), len(staged), int(displayID))
+	if len(creds) > 0 {
+		// The credentials are linked to the query task that extracted them
+		err = credentials.Save(lookup.TaskID, creds)
+		if err != nil {
+			resp.Error = err.Error()
+			resp.Success = false
+			logging.LogError(err, "returning with error")
+			return
+		}
+		if err = credentials.MarkSaved(lookup.TaskID, task.Task.ID, len(creds)); err != nil {
+			logging.LogError(err, "the credentials were saved but the task was not tagged as saved", "Task", lookup.TaskID)
+		}
+		stdout += credentials.Table(creds)
+	}
+	if len(existing) > 0 {
+		stdout += fmt.Sprintf("\n%d credential(s) were already in Mythic and were skipped\n%s", len(existing), credentials.Table(existing))
+	}
+
+	msg := mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   task.Task.ID,
+		Response: []byte(stdout),
+	}
+
+	r, err := mythicrpc.SendMythicRPCResponseCreate(msg)
+	if err != nil {
+		resp.Error = fmt.Sprintf("Failed to send response: %s", err.Error())
+		resp.Success = false
+		logging.LogError(err, "there was an error sending the Mythic RPC Response Create message")
+		return
+	}
+
+	disp := fmt.Sprintf("from task %d", int(displayID))
+	if len(exclude) > 0 {
+		disp += fmt.Sprintf(" excluding %s", strings.Join(exclude, ", "))
+	}
+	resp.DisplayParams = &disp
+	resp.Success = true
+	resp.Completed = &r.Success
+	return
+}
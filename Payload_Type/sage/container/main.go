@@ -13,6 +13,10 @@ import (
 	// Internal
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/commands"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/payload/build"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/credentials"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mythic"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/schema"
 )
 
 func main() {
@@ -33,6 +37,9 @@ func main() {
 	// Add the Sage payload build function definition
 	payloadService.AddBuildFunction(build.Build)
 
+	// Add the built-in schemas operators can constrain a query's answer to before the commands list them
+	schema.Register(credentials.Schema())
+
 	// Add the Sage agent commands
 	for _, command := range commands.Commands() {
 		payloadService.AddCommand(command)
@@ -41,6 +48,16 @@ func main() {
 	// Get the Sage icon and add it
 	payloadService.AddIcon(filepath.Join(".", "..", "sage.svg"))
 
+	// Add the built-in Mythic tools before any MCP servers are connected so their alias is reserved
+	mcp.RegisterProvider(mythic.Tools{})
+
+	// Reconnect to the MCP servers declared in the configuration file or connected before the container restarted
+	err = mcp.Reconnect()
+	if err != nil {
+		logging.LogError(err, "there was an error reconnecting to one or more MCP servers")
+		// Do not return, keep going
+	}
+
 	// Start the container
 	MythicContainer.StartAndRunForever([]MythicContainer.MythicServices{MythicContainer.MythicServicePayload})
 }
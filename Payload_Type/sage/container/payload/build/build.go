@@ -148,6 +148,30 @@ func NewPayload() (structs.PayloadType, error) {
 		ParameterType: structs.BUILD_PARAMETER_TYPE_STRING,
 		DefaultValue:  "us-east-1",
 	}
+	// AWS PROFILE
+	awsProfile := structs.BuildParameter{
+		Name:          "AWS_PROFILE",
+		Description:   "[OPTIONAL] The named profile (AWS_PROFILE) from the AWS config files mounted in the Sage container to use for Bedrock",
+		Required:      false,
+		ParameterType: structs.BUILD_PARAMETER_TYPE_STRING,
+		DefaultValue:  "",
+	}
+	// AWS ROLE ARN
+	awsRoleARN := structs.BuildParameter{
+		Name:          "AWS_ROLE_ARN",
+		Description:   "[OPTIONAL] The ARN of the IAM role (AWS_ROLE_ARN) to assume for Bedrock",
+		Required:      false,
+		ParameterType: structs.BUILD_PARAMETER_TYPE_STRING,
+		DefaultValue:  "",
+	}
+	// AWS ROLE EXTERNAL ID
+	awsRoleExternalID := structs.BuildParameter{
+		Name:          "AWS_ROLE_EXTERNAL_ID",
+		Description:   "[OPTIONAL] The external ID (AWS_ROLE_EXTERNAL_ID) required to assume the AWS_ROLE_ARN role",
+		Required:      false,
+		ParameterType: structs.BUILD_PARAMETER_TYPE_STRING,
+		DefaultValue:  "",
+	}
 
 	// Add the build parameters to the payload
 	payload.BuildParameters = []structs.BuildParameter{
@@ -159,6 +183,9 @@ func NewPayload() (structs.PayloadType, error) {
 		awsSecretAccessKey,
 		awsSessionToken,
 		awsRegion,
+		awsProfile,
+		awsRoleARN,
+		awsRoleExternalID,
 	}
 
 	// Add build step
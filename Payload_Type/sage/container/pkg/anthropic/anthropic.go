@@ -5,13 +5,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 	"strings"
+	"sync"
 
 	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/awsconfig"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
 	sageMCP "github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
 	sageMessage "github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/message"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/models"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/schema"
 
 	// Mythic
 	structs "github.com/MythicMeta/MythicContainer/agent_structs"
@@ -24,11 +27,12 @@ import (
 	"github.com/anthropics/anthropic-sdk-go/packages/param"
 	"github.com/anthropics/anthropic-sdk-go/shared/constant"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// maxParallelTools is the number of tool calls from a single turn that run at the same time
+const maxParallelTools = 4
+
 func Chat(task *structs.PTTaskMessageAllData, msgs []sageMessage.Message, useTools bool, verbose bool) (response []sageMessage.Message, err error) {
 	var messages []anthropic.MessageParam
 
@@ -38,41 +42,11 @@ func Chat(task *structs.PTTaskMessageAllData, msgs []sageMessage.Message, useToo
 		return response, err
 	}
 
-	// Get the model provider
-	provider, err := env.Get(task, "provider")
+	client, err := GetClient(task)
 	if err != nil {
 		return response, err
 	}
 
-	var opt option.RequestOption
-	if strings.ToLower(provider) == "bedrock" {
-		// Get the AWS config
-		cfg, err := GetAWSConfig(task)
-		if err != nil {
-			return response, err
-		}
-		opt = bedrock.WithConfig(cfg)
-	} else {
-		ANTHROPIC_API_KEY, err := env.Get(task, "API_KEY")
-		if err == nil {
-			opt = option.WithAPIKey(ANTHROPIC_API_KEY)
-		} else {
-			ANTHROPIC_API_KEY = os.Getenv("ANTHROPIC_API_KEY")
-			if ANTHROPIC_API_KEY != "" {
-				opt = option.WithAPIKey(ANTHROPIC_API_KEY)
-			} else {
-				ANTHROPIC_API_KEY = os.Getenv("ANTHROPIC_AUTH_TOKEN")
-				if ANTHROPIC_API_KEY != "" {
-					opt = option.WithAuthToken(ANTHROPIC_API_KEY)
-				} else {
-					return response, errors.New("unable to find API_KEY, ANTHROPIC_API_KEY, or ANTHROPIC_AUTH_TOKEN in task, secrets, or environment variables")
-				}
-			}
-		}
-	}
-
-	client := anthropic.NewClient(opt)
-
 	for _, msg := range msgs {
 		tbp := anthropic.TextBlockParam{
 			Text: msg.Content,
@@ -98,14 +72,31 @@ func Chat(task *structs.PTTaskMessageAllData, msgs []sageMessage.Message, useToo
 		Messages:  messages,
 	}
 
-	// Get MCP Tools
+	// Get MCP Tools selected for this session
+	toolbox := sageMCP.NewToolbox(task)
 	if useTools {
 		// Add the tools to the request body
-		body.Tools = mcpTooltoAnthropicTool(sageMCP.GetAllTools())
+		body.Tools = mcpTooltoAnthropicTool(toolbox.Tools())
+	}
+
+	// Anthropic does not have a JSON response format, so the answer is forced through a tool whose input is the schema
+	responseSchema, err := schema.FromTask(task)
+	if err != nil {
+		return response, err
+	}
+	if responseSchema != nil {
+		body.Tools = append(body.Tools, schemaTool(*responseSchema))
+		if useTools {
+			// The model can still use the MCP tools but must finish by calling the schema tool
+			body.ToolChoice = anthropic.ToolChoiceUnionParam{OfToolChoiceAny: &anthropic.ToolChoiceAnyParam{}}
+		} else {
+			body.ToolChoice = anthropic.ToolChoiceUnionParam{OfToolChoiceTool: &anthropic.ToolChoiceToolParam{Name: responseSchema.Name}}
+		}
 	}
 
 	// Send the initial request and iterate over all response messages until we reach a stopping point
 	done := false
+	var usage sageMessage.Usage
 	for !done {
 		var message *anthropic.Message
 		message, err = client.Messages.New(context.TODO(), body)
@@ -114,6 +105,7 @@ func Chat(task *structs.PTTaskMessageAllData, msgs []sageMessage.Message, useToo
 			break
 		}
 		logging.LogDebug("🐞 Anthropic Response Message", "Message", message)
+		usage = usage.Add(sageMessage.Usage{InputTokens: message.Usage.InputTokens, OutputTokens: message.Usage.OutputTokens})
 		switch message.StopReason {
 		case anthropic.MessageStopReasonEndTurn: // the model reached a natural stopping point
 			done = true
@@ -125,20 +117,14 @@ func Chat(task *structs.PTTaskMessageAllData, msgs []sageMessage.Message, useToo
 			done = true
 			messages = append(messages, message.ToParam())
 		case anthropic.MessageStopReasonToolUse: // the model invoked one or more tools
-			var trbp anthropic.ToolResultBlockParam
-			trbp, err = toolUse(message.Content, &messages)
-			if err != nil {
-				err = fmt.Errorf("😡 Failed to execute tool: %w", err)
+			if answer, ok := schemaAnswer(message.Content, responseSchema); ok {
+				done = true
+				messages = append(messages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(string(answer))))
 				break
 			}
-			cbpu := anthropic.ContentBlockParamUnion{
-				OfRequestToolResultBlock: &trbp,
-			}
-			mp := anthropic.MessageParam{
-				Role:    anthropic.MessageParamRoleUser,
-				Content: []anthropic.ContentBlockParamUnion{cbpu},
-			}
-			messages = append(messages, mp)
+			// Every tool_use block in the turn stays in one assistant message and every tool_result goes back in one user message
+			messages = append(messages, message.ToParam())
+			messages = append(messages, anthropic.NewUserMessage(toolUse(message.Content, toolbox)...))
 			body.Messages = messages
 		default:
 			err = fmt.Errorf("😡 Unknown Anthropic stop reason: %v", message.StopReason)
@@ -149,6 +135,7 @@ func Chat(task *structs.PTTaskMessageAllData, msgs []sageMessage.Message, useToo
 	}
 
 	// Loop through the messages and return the new ones
+	tools := make(map[string]string) // tool use ID to tool name
 	for i := len(msgs); i < len(messages); i++ {
 		m := messages[i]
 		for _, c := range m.Content {
@@ -168,89 +155,141 @@ func Chat(task *structs.PTTaskMessageAllData, msgs []sageMessage.Message, useToo
 					input = fmt.Sprintf("%v", c.OfRequestToolUseBlock.Input)
 				}
 				r.Content = fmt.Sprintf("🛠️ Tool Use Block - ID: %s, Tool: %s, Input: %+v", c.OfRequestToolUseBlock.ID, c.OfRequestToolUseBlock.Name, input)
+				r.Kind = sageMessage.ToolCall
+				r.Tool = c.OfRequestToolUseBlock.Name
+				tools[c.OfRequestToolUseBlock.ID] = c.OfRequestToolUseBlock.Name
 			}
 			if c.OfRequestToolResultBlock != nil {
 				r.Content = fmt.Sprintf("🛠️ Tool Result Block - ID: %s, Result:\n%s", c.OfRequestToolResultBlock.ToolUseID, c.OfRequestToolResultBlock.Content[0].OfRequestTextBlock.Text)
+				r.Kind = sageMessage.ToolResult
+				r.Tool = tools[c.OfRequestToolResultBlock.ToolUseID]
+				if images := len(c.OfRequestToolResultBlock.Content) - 1; images > 0 {
+					r.Content += fmt.Sprintf("\n🖼️ %d image(s) were returned to the model", images)
+				}
 			}
 			if c.OfRequestImageBlock != nil {
 				r.Content = "⚠️ Unhandled Message Type: Image Block"
 			}
 			if c.OfRequestThinkingBlock != nil {
 				r.Content = fmt.Sprintf("<🤔 Thinking - Signature: %s>\n%s</🤔 Thinking>", c.OfRequestThinkingBlock.Signature, c.OfRequestThinkingBlock.Thinking)
+				r.Kind = sageMessage.Thinking
 			}
 			if c.OfRequestRedactedThinkingBlock != nil {
 				r.Content = fmt.Sprintf("<🔒 Redacted Thinking>\n%s</🔒 Redacted Thinking>", c.OfRequestRedactedThinkingBlock.Data)
+				r.Kind = sageMessage.Thinking
 			}
 			response = append(response, r)
 		}
 	}
+	if len(response) > 0 {
+		response[len(response)-1].Usage = &usage
+	}
 	return
 }
 
-func toolUse(content []anthropic.ContentBlockUnion, messages *[]anthropic.MessageParam) (trb anthropic.ToolResultBlockParam, err error) {
+// toolUse runs every tool the model called in the turn, at most maxParallelTools at a time, and returns the tool results
+// in the order the model called the tools. Failed calls are returned to the model as error results.
+func toolUse(content []anthropic.ContentBlockUnion, toolbox *sageMCP.Toolbox) (results []anthropic.ContentBlockParamUnion) {
+	var calls []anthropic.ToolUseBlock
 	for _, m := range content {
 		switch variant := m.AsAny().(type) {
 		case anthropic.TextBlock:
 			logging.LogDebug("🤖 Tool Use TextBlock", "Text", variant.Text)
-			msg := anthropic.MessageParam{
-				Role: anthropic.MessageParamRoleAssistant,
-			}
-			x := variant.ToParam()
-			tbp := anthropic.ContentBlockParamUnion{
-				OfRequestTextBlock: &x,
-			}
-			msg.Content = append(msg.Content, tbp)
-			*messages = append(*messages, msg)
 		case anthropic.ToolUseBlock:
-			//fmt.Printf("🛠️ ToolUseBlock - ID: %s, Tool: %s\n", variant.ID, variant.Name)
-			logging.LogDebug("🛠️ Tool Use ToolUseBlock", m, "Input", variant.Input)
-			msg := anthropic.MessageParam{
-				Role: anthropic.MessageParamRoleAssistant,
-			}
-			x := variant.ToParam()
-			tbp := anthropic.ContentBlockParamUnion{
-				OfRequestToolUseBlock: &x,
-			}
-			msg.Content = append(msg.Content, tbp)
-			*messages = append(*messages, msg)
-			trb, err = ExecuteTools(variant)
-		case anthropic.ThinkingBlock:
-			err = fmt.Errorf("⚠️ Unhandled ContentBlockUnion Variant (ThinkingBlock): %+v", variant)
-		case anthropic.RedactedThinkingBlock:
-			err = fmt.Errorf("⚠️ Unhandled ContentBlockUnion Variant (RedactedThinkingBlock): %+v", variant)
-		default:
-			err = fmt.Errorf("⚠️ Unhandled ContentBlockUnion Variant (%T): %+v", variant, variant)
+			logging.LogDebug("🛠️ Tool Use ToolUseBlock", "Tool", variant.Name, "Input", variant.Input)
+			calls = append(calls, variant)
 		}
 	}
-	return
-}
 
-func ExecuteTools(tub anthropic.ToolUseBlock) (trb anthropic.ToolResultBlockParam, err error) {
-	// Convert JSON to map
-	var args map[string]interface{}
-	err = json.Unmarshal(tub.Input, &args)
-	if err != nil {
-		return
+	trbs := make([]anthropic.ToolResultBlockParam, len(calls))
+	workers := make(chan struct{}, maxParallelTools)
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workers <- struct{}{}
+			defer func() { <-workers }()
+			trbs[i] = ExecuteTools(call, toolbox)
+		}()
 	}
+	wg.Wait()
 
-	trb.IsError = param.NewOpt(false)
-
-	var response string
-	response, err = sageMCP.ExecuteTool(tub.Name, args)
-	if err != nil {
-		trb.IsError = param.NewOpt(true)
+	for i := range trbs {
+		results = append(results, anthropic.ContentBlockParamUnion{OfRequestToolResultBlock: &trbs[i]})
 	}
+	return
+}
+
+func ExecuteTools(tub anthropic.ToolUseBlock, toolbox *sageMCP.Toolbox) (trb anthropic.ToolResultBlockParam) {
+	result := toolbox.Call(tub.Name, tub.Input)
+	trb.IsError = param.NewOpt(result.IsError)
 
+	// The text block is always first because it is what gets returned to the operator
 	resp := anthropic.ToolResultBlockParamContentUnion{
-		OfRequestTextBlock: &anthropic.TextBlockParam{Text: response},
+		OfRequestTextBlock: &anthropic.TextBlockParam{Text: result.Text},
 	}
 
 	trb.ToolUseID = tub.ID
 	trb.Content = []anthropic.ToolResultBlockParamContentUnion{resp}
 
+	for _, image := range result.Images {
+		mediaType := anthropic.Base64ImageSourceMediaType(image.MIMEType)
+		switch mediaType {
+		case anthropic.Base64ImageSourceMediaTypeImageJPEG, anthropic.Base64ImageSourceMediaTypeImagePNG,
+			anthropic.Base64ImageSourceMediaTypeImageGIF, anthropic.Base64ImageSourceMediaTypeImageWebP:
+			trb.Content = append(trb.Content, anthropic.ToolResultBlockParamContentUnion{
+				OfRequestImageBlock: &anthropic.ImageBlockParam{
+					Source: anthropic.ImageBlockParamSourceUnion{
+						OfBase64ImageSource: &anthropic.Base64ImageSourceParam{
+							Data:      image.Data,
+							MediaType: mediaType,
+						},
+					},
+				},
+			})
+		default:
+			resp.OfRequestTextBlock.Text += fmt.Sprintf("\n⚠️ The tool returned an image with the unsupported media type %s", image.MIMEType)
+		}
+	}
+
 	return
 }
 
+// schemaTool returns the tool the model calls with its answer when the answer must follow a JSON schema
+func schemaTool(s schema.Schema) anthropic.ToolUnionParam {
+	definition := s.Wrap()
+	extra := make(map[string]interface{})
+	for k, v := range definition {
+		if k != "type" && k != "properties" {
+			extra[k] = v
+		}
+	}
+	return anthropic.ToolUnionParam{
+		OfTool: &anthropic.ToolParam{
+			Name:        s.Name,
+			Description: param.NewOpt(fmt.Sprintf("Call this tool with your final answer. %s", s.Description)),
+			InputSchema: anthropic.ToolInputSchemaParam{
+				Properties:  definition["properties"],
+				ExtraFields: extra,
+			},
+		},
+	}
+}
+
+// schemaAnswer returns the input of the schema tool call as the model's answer, if the model called it
+func schemaAnswer(content []anthropic.ContentBlockUnion, s *schema.Schema) (json.RawMessage, bool) {
+	if s == nil {
+		return nil, false
+	}
+	for _, block := range content {
+		if tub, ok := block.AsAny().(anthropic.ToolUseBlock); ok && tub.Name == s.Name {
+			return s.Unwrap(tub.Input), true
+		}
+	}
+	return nil, false
+}
+
 // mcpTooltoAnthropicTool converts MCP tools to Anthropics tools format
 func mcpTooltoAnthropicTool(mcpTools []mcp.Tool) (tools []anthropic.ToolUnionParam) {
 	for _, tool := range mcpTools {
@@ -268,47 +307,97 @@ func mcpTooltoAnthropicTool(mcpTools []mcp.Tool) (tools []anthropic.ToolUnionPar
 	return
 }
 
-func GetAWSConfig(task *structs.PTTaskMessageAllData) (cfg aws.Config, err error) {
-	// Get the AWS_ACCESS_KEY_ID
-	AWS_ACCESS_KEY_ID, err := env.Get(task, "AWS_ACCESS_KEY_ID")
+// GetClient returns the Anthropic client for the task's provider.
+// Bedrock uses the task's AWS configuration; Anthropic uses API_KEY, ANTHROPIC_API_KEY, or ANTHROPIC_AUTH_TOKEN.
+func GetClient(task *structs.PTTaskMessageAllData) (client anthropic.Client, err error) {
+	// Get the model provider
+	provider, err := env.Get(task, "provider")
 	if err != nil {
-		return cfg, fmt.Errorf("there was an error getting the 'AWS_ACCESS_KEY_ID' argument: %s", err)
+		return
 	}
 
-	// Get the AWS_SECRET_ACCESS_KEY
-	AWS_SECRET_ACCESS_KEY, err := env.Get(task, "AWS_SECRET_ACCESS_KEY")
-	if err != nil {
-		return cfg, fmt.Errorf("there was an error getting the 'AWS_SECRET_ACCESS_KEY' argument: %s", err)
+	var opt option.RequestOption
+	if strings.ToLower(provider) == "bedrock" {
+		// Get the AWS config
+		cfg, err := GetAWSConfig(task)
+		if err != nil {
+			return client, err
+		}
+		opt = bedrock.WithConfig(cfg)
+	} else {
+		ANTHROPIC_API_KEY, err := env.Get(task, "API_KEY")
+		if err != nil {
+			ANTHROPIC_API_KEY, err = env.Get(task, "ANTHROPIC_API_KEY")
+		}
+		if err == nil {
+			opt = option.WithAPIKey(ANTHROPIC_API_KEY)
+		} else {
+			ANTHROPIC_AUTH_TOKEN, err := env.Get(task, "ANTHROPIC_AUTH_TOKEN")
+			if err != nil {
+				return client, errors.New("unable to find API_KEY, ANTHROPIC_API_KEY, or ANTHROPIC_AUTH_TOKEN in task, secrets, or environment variables")
+			}
+			opt = option.WithAuthToken(ANTHROPIC_AUTH_TOKEN)
+		}
 	}
+	return anthropic.NewClient(opt), nil
+}
 
-	// Get the AWS_SESSION_TOKEN
-	AWS_SESSION_TOKEN, err := env.Get(task, "AWS_SESSION_TOKEN")
-	if err != nil {
-		return cfg, fmt.Errorf("there was an error getting the 'AWS_SESSION_TOKEN' argument: %s", err)
-	}
+// family is the context window and capabilities Anthropic documents for a Claude model family
+type family struct {
+	prefix        string
+	contextWindow int
+	capabilities  []string
+}
 
-	// Get the AWS_DEFAULT_REGION from the User's secrets
-	AWS_DEFAULT_REGION, err := env.Get(task, "AWS_DEFAULT_REGION")
-	if err != nil {
-		return cfg, fmt.Errorf("there was an error getting the 'AWS_DEFAULT_REGION' argument: %s", err)
-	}
+// families are the Claude model families by model ID prefix, newest first. The Models API does not return the
+// context window or capabilities, so models from a family that is not in the table are listed without them.
+var families = []family{
+	{"claude-opus-4", 200000, []string{"text+image → text", "streaming", "tools", "extended thinking"}},
+	{"claude-sonnet-4", 200000, []string{"text+image → text", "streaming", "tools", "extended thinking"}},
+	{"claude-haiku-4", 200000, []string{"text+image → text", "streaming", "tools", "extended thinking"}},
+	{"claude-3-7-sonnet", 200000, []string{"text+image → text", "streaming", "tools", "extended thinking"}},
+	{"claude-3-5-sonnet", 200000, []string{"text+image → text", "streaming", "tools"}},
+	{"claude-3-5-haiku", 200000, []string{"text+image → text", "streaming", "tools"}},
+	{"claude-3-opus", 200000, []string{"text+image → text", "streaming", "tools"}},
+	{"claude-3-sonnet", 200000, []string{"text+image → text", "streaming", "tools"}},
+	{"claude-3-haiku", 200000, []string{"text+image → text", "streaming", "tools"}},
+}
 
-	// Setup the AWS Config
-	ctx := context.Background()
-	cfg, err = config.LoadDefaultConfig(
-		ctx,
-		config.WithCredentialsProvider(
-			credentials.NewStaticCredentialsProvider(
-				AWS_ACCESS_KEY_ID,
-				AWS_SECRET_ACCESS_KEY,
-				AWS_SESSION_TOKEN,
-			),
-		),
-		config.WithRegion(AWS_DEFAULT_REGION),
-	)
+// List returns the models from the Anthropic Models API with the context window and capabilities of their family
+func List(task *structs.PTTaskMessageAllData) (list []models.Model, err error) {
+	client, err := GetClient(task)
 	if err != nil {
-		err = fmt.Errorf("configuration error, %v", err)
+		return
 	}
 
+	pager := client.Models.ListAutoPaging(context.Background(), anthropic.ModelListParams{})
+	for pager.Next() {
+		m := pager.Current()
+		model := models.Model{
+			ID:   m.ID,
+			Name: m.DisplayName,
+		}
+		for _, f := range families {
+			if strings.HasPrefix(m.ID, f.prefix) {
+				model.ContextWindow = f.contextWindow
+				model.Capabilities = f.capabilities
+				break
+			}
+		}
+		// The release date is the Unix epoch when Anthropic does not know it
+		if m.CreatedAt.Unix() > 0 {
+			created := m.CreatedAt
+			model.Created = &created
+		}
+		list = append(list, model)
+	}
+	if err = pager.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list Anthropic models: %w", err)
+	}
 	return
 }
+
+// GetAWSConfig returns the AWS configuration for the task from the shared credential resolver
+func GetAWSConfig(task *structs.PTTaskMessageAllData) (cfg aws.Config, err error) {
+	return awsconfig.Get(task)
+}
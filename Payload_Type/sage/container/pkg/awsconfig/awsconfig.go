@@ -0,0 +1,209 @@
+// Package awsconfig resolves the AWS configuration, and credentials, used to call Amazon Bedrock.
+// Both the Bedrock and the Anthropic on Bedrock providers share it so credentials are resolved, and cached, the same way.
+package awsconfig
+
+import (
+	// Standard
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+
+	// 3rd Party
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Keys are the settings, looked up with env.Get, that select how AWS credentials are resolved
+var Keys = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_DEFAULT_REGION",
+	"AWS_PROFILE",
+	"AWS_CONFIG_FILE",
+	"AWS_SHARED_CREDENTIALS_FILE",
+	"AWS_ROLE_ARN",
+	"AWS_ROLE_EXTERNAL_ID",
+	"AWS_ROLE_SESSION_NAME",
+	"AWS_WEB_IDENTITY_TOKEN_FILE",
+}
+
+// cache holds the resolved configurations by a hash of the settings that produced them.
+// The configurations wrap their credentials in an aws.CredentialsCache, so assumed role credentials are reused across
+// tasks until they expire instead of calling STS for every task. A configuration is dropped once the credentials it
+// resolved with expire so expired temporary credentials, like those from an AWS profile, are not reused.
+// Configurations are resolved without holding the lock; tasks with the same settings wait on the one being resolved.
+var cache = struct {
+	sync.Mutex
+	configs map[string]entry
+	pending map[string]*call
+}{configs: make(map[string]entry), pending: make(map[string]*call)}
+
+// entry is a resolved configuration and when its credentials expire. Credentials that do not expire have a zero time.
+type entry struct {
+	cfg     aws.Config
+	expires time.Time
+}
+
+// expired returns true if the entry's credentials have expired
+func (e entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// call is a configuration that is being resolved; done is closed when cfg and err are set
+type call struct {
+	done chan struct{}
+	cfg  aws.Config
+	err  error
+}
+
+// Get returns the AWS configuration for the task. Credentials are resolved in this order:
+//  1. AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, with an optional AWS_SESSION_TOKEN
+//  2. The AWS_PROFILE named profile from the shared config files, which can be mounted into the container and selected
+//     with AWS_CONFIG_FILE and AWS_SHARED_CREDENTIALS_FILE
+//  3. The default AWS credential chain: environment variables, shared config files, web identity, container (ECS/EKS)
+//     roles, and the EC2 instance role
+//
+// If AWS_ROLE_ARN is set, those credentials are used to assume the role, with the optional AWS_ROLE_EXTERNAL_ID and
+// AWS_ROLE_SESSION_NAME. When AWS_WEB_IDENTITY_TOKEN_FILE is also set, the role is assumed with the web identity token.
+func Get(task *structs.PTTaskMessageAllData) (cfg aws.Config, err error) {
+	settings := make(map[string]string)
+	for _, key := range Keys {
+		// Every setting is optional
+		settings[key], _ = env.Get(task, key)
+	}
+	key := hash(settings)
+
+	cache.Lock()
+	now := time.Now()
+	for k, e := range cache.configs {
+		if e.expired(now) {
+			delete(cache.configs, k)
+		}
+	}
+	if e, ok := cache.configs[key]; ok {
+		cache.Unlock()
+		return e.cfg, nil
+	}
+	if c, ok := cache.pending[key]; ok {
+		cache.Unlock()
+		<-c.done
+		return c.cfg, c.err
+	}
+	c := &call{done: make(chan struct{})}
+	cache.pending[key] = c
+	cache.Unlock()
+
+	var expires time.Time
+	c.cfg, expires, c.err = resolve(settings)
+
+	cache.Lock()
+	delete(cache.pending, key)
+	if c.err == nil {
+		cache.configs[key] = entry{cfg: c.cfg, expires: expires}
+	}
+	cache.Unlock()
+	close(c.done)
+	return c.cfg, c.err
+}
+
+// resolve loads the AWS configuration for the settings and returns when the credentials it resolved expire
+func resolve(settings map[string]string) (cfg aws.Config, expires time.Time, err error) {
+	ctx := context.Background()
+
+	var options []func(*config.LoadOptions) error
+	if settings["AWS_DEFAULT_REGION"] != "" {
+		options = append(options, config.WithRegion(settings["AWS_DEFAULT_REGION"]))
+	}
+	if settings["AWS_CONFIG_FILE"] != "" {
+		options = append(options, config.WithSharedConfigFiles([]string{settings["AWS_CONFIG_FILE"]}))
+	}
+	if settings["AWS_SHARED_CREDENTIALS_FILE"] != "" {
+		options = append(options, config.WithSharedCredentialsFiles([]string{settings["AWS_SHARED_CREDENTIALS_FILE"]}))
+	}
+
+	source := "default credential chain"
+	switch {
+	case settings["AWS_ACCESS_KEY_ID"] != "" && settings["AWS_SECRET_ACCESS_KEY"] != "":
+		source = "static credentials"
+		options = append(options, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(
+				settings["AWS_ACCESS_KEY_ID"],
+				settings["AWS_SECRET_ACCESS_KEY"],
+				settings["AWS_SESSION_TOKEN"],
+			),
+		))
+	case settings["AWS_ACCESS_KEY_ID"] != "" || settings["AWS_SECRET_ACCESS_KEY"] != "":
+		return cfg, expires, fmt.Errorf("both AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required to use static AWS credentials")
+	case settings["AWS_PROFILE"] != "":
+		source = fmt.Sprintf("profile %s", settings["AWS_PROFILE"])
+		options = append(options, config.WithSharedConfigProfile(settings["AWS_PROFILE"]))
+	}
+
+	cfg, err = config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return cfg, expires, fmt.Errorf("configuration error, %v", err)
+	}
+	if cfg.Region == "" {
+		return cfg, expires, fmt.Errorf("the AWS region is not set, provide AWS_DEFAULT_REGION or set the region in the AWS profile")
+	}
+
+	if role := settings["AWS_ROLE_ARN"]; role != "" {
+		client := sts.NewFromConfig(cfg)
+		if settings["AWS_WEB_IDENTITY_TOKEN_FILE"] != "" {
+			source = fmt.Sprintf("web identity role %s", role)
+			cfg.Credentials = aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(
+				client,
+				role,
+				stscreds.IdentityTokenFile(settings["AWS_WEB_IDENTITY_TOKEN_FILE"]),
+				func(o *stscreds.WebIdentityRoleOptions) {
+					o.RoleSessionName = settings["AWS_ROLE_SESSION_NAME"]
+				},
+			))
+		} else {
+			source = fmt.Sprintf("role %s assumed with the %s", role, source)
+			cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(client, role, func(o *stscreds.AssumeRoleOptions) {
+				if settings["AWS_ROLE_SESSION_NAME"] != "" {
+					o.RoleSessionName = settings["AWS_ROLE_SESSION_NAME"]
+				}
+				if settings["AWS_ROLE_EXTERNAL_ID"] != "" {
+					o.ExternalID = aws.String(settings["AWS_ROLE_EXTERNAL_ID"])
+				}
+			}))
+		}
+	}
+
+	// Resolve the credentials now so a misconfiguration is reported before the model is called
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return cfg, expires, fmt.Errorf("there was an error getting AWS credentials from the %s: %v", source, err)
+	}
+	if creds.CanExpire {
+		expires = creds.Expires
+	}
+	logging.LogDebug("Resolved AWS credentials", "Source", source, "Region", cfg.Region, "Expires", expires)
+	return
+}
+
+// hash returns a key for the settings that does not hold the secrets themselves
+func hash(settings map[string]string) string {
+	h := sha256.New()
+	for _, key := range Keys {
+		h.Write([]byte(key + "=" + strings.TrimSpace(settings[key]) + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
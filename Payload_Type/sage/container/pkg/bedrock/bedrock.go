@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/awsconfig"
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
 
 	// Mythic
@@ -18,55 +19,13 @@ import (
 
 	//AWS
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	awsbedrock "github.com/aws/aws-sdk-go-v2/service/bedrock"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
 )
 
+// GetAWSConfig returns the AWS configuration for the task from the shared credential resolver
 func GetAWSConfig(task *structs.PTTaskMessageAllData) (cfg aws.Config, err error) {
-	// Get the AWS_ACCESS_KEY_ID
-	AWS_ACCESS_KEY_ID, err := env.Get(task, "AWS_ACCESS_KEY_ID")
-	if err != nil {
-		return cfg, fmt.Errorf("there was an error getting the 'AWS_ACCESS_KEY_ID' argument: %s", err)
-	}
-
-	// Get the AWS_SECRET_ACCESS_KEY
-	AWS_SECRET_ACCESS_KEY, err := env.Get(task, "AWS_SECRET_ACCESS_KEY")
-	if err != nil {
-		return cfg, fmt.Errorf("there was an error getting the 'AWS_SECRET_ACCESS_KEY' argument: %s", err)
-	}
-
-	// Get the AWS_SESSION_TOKEN
-	AWS_SESSION_TOKEN, err := env.Get(task, "AWS_SESSION_TOKEN")
-	if err != nil {
-		return cfg, fmt.Errorf("there was an error getting the 'AWS_SESSION_TOKEN' argument: %s", err)
-	}
-
-	// Get the AWS_DEFAULT_REGION from the User's secrets
-	AWS_DEFAULT_REGION, err := env.Get(task, "AWS_DEFAULT_REGION")
-	if err != nil {
-		return cfg, fmt.Errorf("there was an error getting the 'AWS_DEFAULT_REGION' argument: %s", err)
-	}
-
-	// Setup the AWS Config
-	ctx := context.Background()
-	cfg, err = config.LoadDefaultConfig(
-		ctx,
-		config.WithCredentialsProvider(
-			credentials.NewStaticCredentialsProvider(
-				AWS_ACCESS_KEY_ID,
-				AWS_SECRET_ACCESS_KEY,
-				AWS_SESSION_TOKEN,
-			),
-		),
-		config.WithRegion(AWS_DEFAULT_REGION),
-	)
-	if err != nil {
-		err = fmt.Errorf("configuration error, %v", err)
-	}
-
-	return
+	return awsconfig.Get(task)
 }
 
 func GetBedrockClient(task *structs.PTTaskMessageAllData) (bedrockClient *awsbedrock.Client, err error) {
@@ -81,35 +40,12 @@ func GetBedrockClient(task *structs.PTTaskMessageAllData) (bedrockClient *awsbed
 
 func GetBedrockRuntimeClient(task *structs.PTTaskMessageAllData) (bedrockRuntimeClient *bedrockruntime.Client, err error) {
 	cfg, err := GetAWSConfig(task)
-
-	bedrockRuntimeClient = bedrockruntime.NewFromConfig(cfg)
-	return bedrockRuntimeClient, nil
-}
-
-func ListFoundationalModels(task *structs.PTTaskMessageAllData) (models []string, err error) {
-	cfg, err := GetAWSConfig(task)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get AWS Config: %v", err)
 	}
 
-	bedrockClient := awsbedrock.NewFromConfig(cfg)
-
-	ctx := context.Background()
-	// Get the Foundational Models
-	input := &awsbedrock.ListFoundationModelsInput{}
-	result, err := bedrockClient.ListFoundationModels(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list foundational models: %v", err)
-	}
-	if len(result.ModelSummaries) == 0 {
-		models = append(models, "There are no foundation models.")
-		return
-	}
-
-	for _, modelSummary := range result.ModelSummaries {
-		models = append(models, *modelSummary.ModelId)
-	}
-	return models, nil
+	bedrockRuntimeClient = bedrockruntime.NewFromConfig(cfg)
+	return bedrockRuntimeClient, nil
 }
 
 // Chat invokes the specified Amazon Bedrock model with the given prompt and data.
@@ -117,10 +53,7 @@ func ListFoundationalModels(task *structs.PTTaskMessageAllData) (models []string
 // - model - The model string to use for inference
 // - prompt - The prompt string to use for inference
 // - data - The data to use for inference
-// - AWS_ACCESS_KEY_ID
-// - AWS_SECRET_ACCESS_KEY
-// - AWS_SESSION_TOKEN
-// - AWS_DEFAULT_REGION
+// AWS credentials are resolved by awsconfig.Get
 func Chat(task *structs.PTTaskMessageAllData, prompt string) (output string, err error) {
 	client, err := GetBedrockRuntimeClient(task)
 	if err != nil {
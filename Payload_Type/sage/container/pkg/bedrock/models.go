@@ -0,0 +1,237 @@
+package bedrock
+
+import (
+	// Standard
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/models"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+
+	//AWS
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsbedrock "github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+)
+
+// Kind is how a Bedrock model is invoked
+type Kind string
+
+const (
+	// Foundation models are invoked on demand with their model ID
+	Foundation Kind = "foundation model"
+	// InferenceProfile models are invoked with a system defined cross-region profile ID (e.g., us.anthropic...) or an
+	// application profile ARN
+	InferenceProfile Kind = "inference profile"
+	// Provisioned models are invoked with the ARN of purchased provisioned throughput
+	Provisioned Kind = "provisioned throughput"
+)
+
+// Model is a Bedrock model ID, inference profile, or provisioned throughput that can be given as the model string
+type Model struct {
+	// ID is the string used to invoke the model: a model ID, inference profile ID or ARN, or provisioned throughput ARN
+	ID string `json:"id"`
+	// Name is the display name
+	Name string `json:"name"`
+	// Kind is how the model is invoked
+	Kind Kind `json:"kind"`
+	// Provider is the company that made the underlying foundation model (e.g., Anthropic)
+	Provider string `json:"provider"`
+	// FoundationModels are the IDs of the foundation models that serve the requests
+	FoundationModels []string `json:"foundation_models"`
+	// InputModalities are the types of input the model accepts (e.g., TEXT, IMAGE)
+	InputModalities []string `json:"input_modalities"`
+	// OutputModalities are the types of output the model returns
+	OutputModalities []string `json:"output_modalities"`
+	// Streaming is true if the model can stream its response
+	Streaming bool `json:"streaming"`
+	// Tools is true if Sage can give the model MCP tools, which requires an Anthropic Claude model
+	Tools bool `json:"tools"`
+	// OnDemand is true if a foundation model can be invoked with its model ID instead of an inference profile
+	OnDemand bool `json:"on_demand"`
+	// Status is the model's lifecycle status, like ACTIVE, LEGACY, or InService
+	Status string `json:"status"`
+}
+
+// modelCache holds the listed models by region and access key so chat messages do not list the models every time
+var modelCache = models.NewCache[Model]()
+
+// ListModels returns the foundation models, inference profiles, and provisioned throughput that can be invoked in the
+// task's AWS account and region. Inference profiles and provisioned throughput take their modalities, streaming, and
+// tool support from their foundation model.
+func ListModels(task *structs.PTTaskMessageAllData) ([]Model, error) {
+	cfg, err := GetAWSConfig(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AWS Config: %v", err)
+	}
+
+	ctx := context.Background()
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AWS credentials: %v", err)
+	}
+	return modelCache.Get(models.Key(cfg.Region, creds.AccessKeyID), func() ([]Model, error) {
+		return listModels(ctx, cfg)
+	})
+}
+
+// listModels calls the Bedrock APIs that list every kind of model
+func listModels(ctx context.Context, cfg aws.Config) (models []Model, err error) {
+	client := awsbedrock.NewFromConfig(cfg)
+
+	result, err := client.ListFoundationModels(ctx, &awsbedrock.ListFoundationModelsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list foundational models: %v", err)
+	}
+	foundation := make(map[string]Model)
+	for _, summary := range result.ModelSummaries {
+		m := foundationModel(summary)
+		foundation[m.ID] = m
+		models = append(models, m)
+	}
+
+	profiles := awsbedrock.NewListInferenceProfilesPaginator(client, &awsbedrock.ListInferenceProfilesInput{})
+	for profiles.HasMorePages() {
+		page, err := profiles.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list inference profiles: %v", err)
+		}
+		for _, summary := range page.InferenceProfileSummaries {
+			var arns []string
+			for _, m := range summary.Models {
+				arns = append(arns, aws.ToString(m.ModelArn))
+			}
+			// System defined profiles are invoked by ID; application profiles only have an ARN
+			id := aws.ToString(summary.InferenceProfileId)
+			if summary.Type == types.InferenceProfileTypeApplication {
+				id = aws.ToString(summary.InferenceProfileArn)
+			}
+			models = append(models, derived(id, aws.ToString(summary.InferenceProfileName), InferenceProfile, string(summary.Status), arns, foundation))
+		}
+	}
+
+	throughputs := awsbedrock.NewListProvisionedModelThroughputsPaginator(client, &awsbedrock.ListProvisionedModelThroughputsInput{})
+	for throughputs.HasMorePages() {
+		page, err := throughputs.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list provisioned model throughputs: %v", err)
+		}
+		for _, summary := range page.ProvisionedModelSummaries {
+			models = append(models, derived(aws.ToString(summary.ProvisionedModelArn), aws.ToString(summary.ProvisionedModelName), Provisioned, string(summary.Status), []string{aws.ToString(summary.FoundationModelArn)}, foundation))
+		}
+	}
+	return
+}
+
+// foundationModel converts the Bedrock summary into a Model
+func foundationModel(summary types.FoundationModelSummary) Model {
+	m := Model{
+		ID:               aws.ToString(summary.ModelId),
+		Name:             aws.ToString(summary.ModelName),
+		Kind:             Foundation,
+		Provider:         aws.ToString(summary.ProviderName),
+		FoundationModels: []string{aws.ToString(summary.ModelId)},
+		Streaming:        aws.ToBool(summary.ResponseStreamingSupported),
+		OnDemand:         slices.Contains(summary.InferenceTypesSupported, types.InferenceTypeOnDemand),
+	}
+	for _, modality := range summary.InputModalities {
+		m.InputModalities = append(m.InputModalities, string(modality))
+	}
+	for _, modality := range summary.OutputModalities {
+		m.OutputModalities = append(m.OutputModalities, string(modality))
+	}
+	if summary.ModelLifecycle != nil {
+		m.Status = string(summary.ModelLifecycle.Status)
+	}
+	m.Tools = m.Provider == "Anthropic" && slices.Contains(m.OutputModalities, string(types.ModelModalityText))
+	return m
+}
+
+// derived returns an inference profile or provisioned throughput with the capabilities of its foundation model.
+// Foundation models from other regions, which are not in the list, are matched by their model ID.
+func derived(id, name string, kind Kind, status string, arns []string, foundation map[string]Model) Model {
+	m := Model{ID: id, Name: name, Kind: kind, Status: status}
+	for _, arn := range arns {
+		modelID := arn[strings.LastIndex(arn, "/")+1:]
+		if slices.Contains(m.FoundationModels, modelID) {
+			continue
+		}
+		m.FoundationModels = append(m.FoundationModels, modelID)
+		if f, ok := foundation[modelID]; ok && m.Provider == "" {
+			m.Provider = f.Provider
+			m.InputModalities = f.InputModalities
+			m.OutputModalities = f.OutputModalities
+			m.Streaming = f.Streaming
+			m.Tools = f.Tools
+		}
+	}
+	// Profiles and provisioned throughput are how on demand invocation is done for their foundation models
+	m.OnDemand = true
+	return m
+}
+
+// ValidateModel returns the model's metadata if Sage can invoke the model string through the Anthropic Messages API on
+// Bedrock, or an error explaining why it can not. If the account is not allowed to list models, the model string is
+// not validated and Bedrock reports any problem when the model is invoked.
+func ValidateModel(task *structs.PTTaskMessageAllData, id string) (*Model, error) {
+	models, err := ListModels(task)
+	if err != nil {
+		logging.LogError(err, "unable to validate the Bedrock model, it will be invoked without validation", "Model", id)
+		return nil, nil
+	}
+	i := slices.IndexFunc(models, func(m Model) bool { return m.ID == id })
+	if i < 0 {
+		return nil, fmt.Errorf("model '%s' is not a Bedrock model ID, inference profile, or provisioned throughput ARN in this region, use the 'list' command to see the available models", id)
+	}
+	m := models[i]
+	switch {
+	case m.Provider != "Anthropic":
+		return nil, fmt.Errorf("model '%s' is a %s model, Sage only supports Anthropic Claude models on Bedrock", id, m.Provider)
+	case !slices.Contains(m.OutputModalities, string(types.ModelModalityText)):
+		return nil, fmt.Errorf("model '%s' does not return text", id)
+	case !m.OnDemand:
+		profiles := []string{}
+		for _, p := range models {
+			if p.Kind == InferenceProfile && slices.Contains(p.FoundationModels, id) {
+				profiles = append(profiles, p.ID)
+			}
+		}
+		return nil, fmt.Errorf("model '%s' can not be invoked on demand, use one of its inference profiles instead: %s", id, strings.Join(profiles, ", "))
+	case m.Kind == InferenceProfile && m.Status != string(types.InferenceProfileStatusActive):
+		return nil, fmt.Errorf("inference profile '%s' is %s", id, m.Status)
+	case m.Kind == Provisioned && m.Status != string(types.ProvisionedModelStatusInService):
+		return nil, fmt.Errorf("provisioned throughput '%s' is %s", id, m.Status)
+	}
+	return &m, nil
+}
+
+// List returns the Bedrock models in the format shared by every provider
+func List(task *structs.PTTaskMessageAllData) (list []models.Model, err error) {
+	bedrockModels, err := ListModels(task)
+	if err != nil {
+		return
+	}
+	for _, m := range bedrockModels {
+		capabilities := []string{string(m.Kind)}
+		if len(m.InputModalities) > 0 {
+			capabilities = append(capabilities, fmt.Sprintf("%s → %s", strings.ToLower(strings.Join(m.InputModalities, "+")), strings.ToLower(strings.Join(m.OutputModalities, "+"))))
+		}
+		if m.Streaming {
+			capabilities = append(capabilities, "streaming")
+		}
+		if m.Tools {
+			capabilities = append(capabilities, "tools")
+		}
+		if m.Status != "" {
+			capabilities = append(capabilities, strings.ToLower(m.Status))
+		}
+		list = append(list, models.Model{ID: m.ID, Name: m.Name, Capabilities: capabilities})
+	}
+	return
+}
@@ -0,0 +1,254 @@
+// Package credentials extracts credentials from tool output with a model and adds them to the Mythic credential store
+package credentials
+
+import (
+	// Standard
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/schema"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+// Arg is the query task argument that enables credential extraction
+const Arg = "extract_credentials"
+
+// Types are the credential types the Mythic credential store accepts
+var Types = []string{"plaintext", "certificate", "hash", "key", "ticket", "cookie", "hex"}
+
+// Credential is a single credential extracted by the model
+type Credential struct {
+	// Realm is the domain, host, or service the credential is valid for
+	Realm string `json:"realm"`
+	// Account is the user or service account name
+	Account string `json:"account"`
+	// Type is one of Types
+	Type string `json:"type"`
+	// Value is the password, hash, key, or ticket
+	Value string `json:"value"`
+	// Comment is where the model found the credential and anything the operator should know about it
+	Comment string `json:"comment"`
+}
+
+// Schema returns the built-in "credentials" schema the model's answer must follow when extracting credentials
+func Schema() schema.Schema {
+	return schema.Schema{
+		Name:        "credentials",
+		Description: "Every credential found in the input (e.g., mimikatz, secretsdump, or configuration file output) with its value copied exactly",
+		Definition: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"credentials": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"realm":   map[string]interface{}{"type": "string", "description": "The domain, host, or service the credential is valid for"},
+							"account": map[string]interface{}{"type": "string", "minLength": 1, "description": "The user or service account name"},
+							"type":    map[string]interface{}{"type": "string", "enum": Types},
+							"value":   map[string]interface{}{"type": "string", "minLength": 1, "description": "The password, hash, key, or ticket exactly as it appears in the input"},
+							"comment": map[string]interface{}{"type": "string", "description": "Where the credential was found (e.g., mimikatz sekurlsa::logonpasswords)"},
+						},
+						"required":             []string{"realm", "account", "type", "value", "comment"},
+						"additionalProperties": false,
+					},
+				},
+			},
+			"required":             []string{"credentials"},
+			"additionalProperties": false,
+		},
+	}
+}
+
+// Parse returns the credentials from an answer that was validated against the Schema
+func Parse(answer string) (credentials []Credential, err error) {
+	var result struct {
+		Credentials []Credential `json:"credentials"`
+	}
+	if err = json.Unmarshal([]byte(answer), &result); err != nil {
+		return nil, fmt.Errorf("the answer does not contain credentials: %w", err)
+	}
+	for i, c := range result.Credentials {
+		if err = c.Validate(); err != nil {
+			return nil, fmt.Errorf("credential %d: %w", i, err)
+		}
+	}
+	return result.Credentials, nil
+}
+
+// Validate returns an error if the credential can not be added to the Mythic credential store
+func (c Credential) Validate() error {
+	if strings.TrimSpace(c.Account) == "" {
+		return errors.New("the account is empty")
+	}
+	if strings.TrimSpace(c.Value) == "" {
+		return errors.New("the value is empty")
+	}
+	if !slices.Contains(Types, c.Type) {
+		return fmt.Errorf("the type '%s' is not one of %v", c.Type, Types)
+	}
+	return nil
+}
+
+// Table returns the credentials as a Markdown table for the operator to review
+func Table(credentials []Credential) string {
+	var b strings.Builder
+	b.WriteString("| # | Realm | Account | Type | Value | Comment |\n|---|---|---|---|---|---|\n")
+	for i, c := range credentials {
+		b.WriteString(fmt.Sprintf("| %d | %s | %s | %s | %s | %s |\n", i, cell(c.Realm), cell(c.Account), cell(c.Type), cell(c.Value), cell(c.Comment)))
+	}
+	return b.String()
+}
+
+// cell escapes the pipes, and replaces the newlines, in the value so it stays in one Markdown table cell
+func cell(value string) string {
+	value = strings.ReplaceAll(value, "|", `\|`)
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(value)
+}
+
+// Exists returns true if the Mythic credential store already has the credential, like one saved by an earlier
+// save-credentials task, so it is not added twice
+func Exists(taskID int, c Credential) (bool, error) {
+	resp, err := mythicrpc.SendMythicRPCCredentialSearch(mythicrpc.MythicRPCCredentialSearchMessage{
+		TaskID: taskID,
+		SearchCredentials: mythicrpc.MythicRPCCredentialSearchCredentialData{
+			Type:       &c.Type,
+			Account:    &c.Account,
+			Realm:      &c.Realm,
+			Credential: &c.Value,
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("there was an error searching the Mythic credential store: %w", err)
+	}
+	if !resp.Success {
+		return false, fmt.Errorf("there was an error searching the Mythic credential store: %s", resp.Error)
+	}
+	// The search can match partial values, so only an identical credential counts
+	return slices.ContainsFunc(resp.Credentials, func(e mythicrpc.MythicRPCCredentialSearchCredentialData) bool {
+		return deref(e.Type) == c.Type && deref(e.Account) == c.Account && deref(e.Realm) == c.Realm && deref(e.Credential) == c.Value
+	}), nil
+}
+
+// deref returns the string the pointer points to, or an empty string for nil
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// Save adds the credentials to the Mythic credential store, linked to the task they were extracted by
+func Save(taskID int, credentials []Credential) error {
+	msg := mythicrpc.MythicRPCCredentialCreateMessage{TaskID: taskID}
+	for _, c := range credentials {
+		msg.Credentials = append(msg.Credentials, mythicrpc.MythicRPCCredentialCreateCredentialData{
+			CredentialType: c.Type,
+			Realm:          c.Realm,
+			Account:        c.Account,
+			Credential:     c.Value,
+			Comment:        c.Comment,
+		})
+	}
+	resp, err := mythicrpc.SendMythicRPCCredentialCreate(msg)
+	if err != nil {
+		return fmt.Errorf("there was an error creating the credentials in Mythic: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("there was an error creating the credentials in Mythic: %s", resp.Error)
+	}
+	return nil
+}
+
+// Enabled returns true if the task asked for credential extraction
+func Enabled(task *structs.PTTaskMessageAllData) bool {
+	enabled, _ := task.Args.GetBooleanArg(Arg)
+	return enabled
+}
+
+const (
+	// source identifies the tags that hold credentials waiting for the operator's confirmation
+	source = "sage-credentials"
+	// savedSource identifies the tags that mark a task's staged credentials as saved
+	savedSource = "sage-credentials-saved"
+)
+
+// Stage attaches the extracted credentials to the task as a tag so the operator can review them before they are saved
+func Stage(taskID int, credentials []Credential) error {
+	return tag(taskID, "sage credentials", "Credentials extracted by a model that are waiting for the operator to save them", "#ffb300", source, map[string]interface{}{"credentials": credentials})
+}
+
+// MarkSaved tags the task to record that its staged credentials were saved by the save-credentials task.
+// MythicRPC can not delete or update tags, so the staged credentials tag stays on the task; delete it in the Mythic UI
+// to remove the staged values.
+func MarkSaved(taskID, savedBy, saved int) error {
+	return tag(taskID, "sage credentials saved", "Credentials extracted by a model that were saved to the Mythic credential store", "#43a047", savedSource, map[string]interface{}{"saved_by": savedBy, "saved": saved})
+}
+
+// tag attaches a tag, of the tag type with the name, to the task
+func tag(taskID int, name, description, color, tagSource string, data map[string]interface{}) error {
+	tagType, err := mythicrpc.SendMythicRPCTagTypeGetOrCreate(mythicrpc.MythicRPCTagTypeGetOrCreateMessage{
+		TaskID:                        taskID,
+		GetOrCreateTagTypeName:        &name,
+		GetOrCreateTagTypeDescription: &description,
+		GetOrCreateTagTypeColor:       &color,
+	})
+	if err != nil {
+		return fmt.Errorf("there was an error getting the %s tag type: %w", name, err)
+	}
+	if !tagType.Success {
+		return fmt.Errorf("there was an error getting the %s tag type: %s", name, tagType.Error)
+	}
+	resp, err := mythicrpc.SendMythicRPCTagCreate(mythicrpc.MythicRPCTagCreateMessage{
+		TagTypeID: tagType.TagType.ID,
+		Source:    tagSource,
+		TaskID:    &taskID,
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("there was an error tagging the task with the %s tag: %w", name, err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("there was an error tagging the task with the %s tag: %s", name, resp.Error)
+	}
+	return nil
+}
+
+// Staged returns the credentials that were staged for the task by Stage
+func Staged(taskID int) (credentials []Credential, err error) {
+	src := source
+	resp, err := mythicrpc.SendMythicRPCTagSearch(mythicrpc.MythicRPCTagSearchMessage{
+		TaskID:          taskID,
+		SearchTagTaskID: &taskID,
+		SearchTagSource: &src,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("there was an error searching the task's tags: %w", err)
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("there was an error searching the task's tags: %s", resp.Error)
+	}
+	for _, tag := range resp.Tags {
+		// The tag data is returned as generic JSON, round trip it to get the credentials back
+		data, err := json.Marshal(tag.Data["credentials"])
+		if err != nil {
+			return nil, err
+		}
+		var c []Credential
+		if err = json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("the task's credentials tag is not valid: %w", err)
+		}
+		credentials = append(credentials, c...)
+	}
+	if len(credentials) == 0 {
+		return nil, fmt.Errorf("task %d does not have any extracted credentials", taskID)
+	}
+	return
+}
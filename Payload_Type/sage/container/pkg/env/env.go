@@ -2,22 +2,52 @@ package env
 
 import (
 	// Standard
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
 
 	// Mythic
 	structs "github.com/MythicMeta/MythicContainer/agent_structs"
 	"github.com/MythicMeta/MythicContainer/logging"
 )
 
-// Get retrieves the value for a given key from the task, user secrets, payload build parameters, or payload container environment variables.
+// Get retrieves the value for a given key from the task, the selected provider profile, user secrets, payload build parameters, or payload container environment variables.
 // It checks the following order:
 // 1. Task Level
-// 2. User Secrets
-// 3. Payload Build Parameters
-// 4. Payload Container Environment Variables
+// 2. Provider Profile selected with the profile key
+// 3. User Secrets
+// 4. Payload Build Parameters
+// 5. Payload Container Environment Variables
 // If the key is not found in any of these, it returns an error.
 func Get(task *structs.PTTaskMessageAllData, key string) (value string, err error) {
+	value, _, err = lookup(task, key, true)
+	return
+}
+
+// ErrNotFound is returned when a key is not set in any of the places Get looks
+var ErrNotFound = errors.New("not found")
+
+// Source is the place a key's value was found
+type Source string
+
+// The places Get looks for a key's value
+const (
+	TaskArg         Source = "task arg"
+	ProviderProfile Source = "provider profile"
+	UserSecret      Source = "user secret"
+	BuildParameter  Source = "build parameter"
+	ContainerEnv    Source = "container env"
+)
+
+// Lookup retrieves the value for the key like Get and also returns where the value was found
+func Lookup(task *structs.PTTaskMessageAllData, key string) (value string, source Source, err error) {
+	return lookup(task, key, true)
+}
+
+// lookup retrieves the value for the key in the order documented on Get.
+// The provider profile is skipped when withProfile is false.
+func lookup(task *structs.PTTaskMessageAllData, key string, withProfile bool) (value string, source Source, err error) {
 	logging.LogDebug(fmt.Sprintf("Getting value for key: %s", key))
 
 	// Check if the key exists in the task
@@ -25,17 +55,30 @@ func Get(task *structs.PTTaskMessageAllData, key string) (value string, err erro
 	if err == nil {
 		if value != "" {
 			logging.LogDebug(fmt.Sprintf("Key %s found in task args", key))
-			return value, nil
+			return value, TaskArg, nil
 		}
 	}
 	logging.LogDebug(fmt.Sprintf("Key %s not found in task args", key))
 
+	// Check if the key exists in the selected provider profile
+	if withProfile {
+		v, ok, err := profileValue(task, key)
+		if err != nil {
+			return "", ProviderProfile, err
+		}
+		if ok {
+			logging.LogDebug(fmt.Sprintf("Key %s found in provider profile", key))
+			return v, ProviderProfile, nil
+		}
+		logging.LogDebug(fmt.Sprintf("Key %s not found in provider profile", key))
+	}
+
 	// Check if the key exists in the user secrets
 	v, ok := task.Secrets[key]
 	if ok {
 		if v.(string) != "" {
 			logging.LogDebug(fmt.Sprintf("Key %s found in user secrets", key))
-			return v.(string), nil
+			return v.(string), UserSecret, nil
 		}
 	}
 	logging.LogDebug(fmt.Sprintf("Key %s not found in user secrets", key))
@@ -45,7 +88,7 @@ func Get(task *structs.PTTaskMessageAllData, key string) (value string, err erro
 		if param.Name == key {
 			if param.Value != "" {
 				logging.LogDebug(fmt.Sprintf("Key %s found in payload build parameters", key))
-				return param.Value.(string), nil
+				return param.Value.(string), BuildParameter, nil
 			}
 		}
 	}
@@ -55,10 +98,32 @@ func Get(task *structs.PTTaskMessageAllData, key string) (value string, err erro
 	value = os.Getenv(key)
 	if value != "" {
 		logging.LogDebug(fmt.Sprintf("Key %s found in payload container environment variables", key))
-		return value, nil
+		return value, ContainerEnv, nil
 	} else {
 		logging.LogDebug(fmt.Sprintf("Key %s not found in payload container environment variables", key))
-		err = fmt.Errorf("key %s not found in task args, user secrets, payload build parameters, or payload container environment variables", key)
-		return "", err
+		err = fmt.Errorf("key %s %w in task args, provider profile, user secrets, payload build parameters, or payload container environment variables", key, ErrNotFound)
+		return "", "", err
 	}
 }
+
+// reference matches ${KEY} references to keys that are resolved with Get
+var reference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Expand replaces every ${KEY} reference in the value with the value for KEY returned by Get.
+// This allows secrets, such as API tokens, to be stored as user secrets and referenced without appearing in the task command line.
+func Expand(task *structs.PTTaskMessageAllData, value string) (string, error) {
+	return expand(task, value, true)
+}
+
+// expand replaces the ${KEY} references in the value, skipping the provider profile when withProfile is false
+func expand(task *structs.PTTaskMessageAllData, value string, withProfile bool) (string, error) {
+	var errs []error
+	expanded := reference.ReplaceAllStringFunc(value, func(ref string) string {
+		v, _, err := lookup(task, reference.FindStringSubmatch(ref)[1], withProfile)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return v
+	})
+	return expanded, errors.Join(errs...)
+}
@@ -0,0 +1,168 @@
+package env
+
+import (
+	// Standard
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+
+	// 3rd Party
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ProfileKey is the setting, usually the task's profile parameter, that selects a named provider profile
+	ProfileKey = "profile"
+	// ProfilesFileKey is the environment variable that overrides the location of the provider profiles file
+	ProfilesFileKey = "SAGE_PROFILES_FILE"
+)
+
+// Profile is a named set of settings for a provider, like an Ollama server in the lab or Claude on Bedrock in GovCloud.
+// The values can reference other keys, such as user secrets, with ${KEY} so credentials do not have to be in the file.
+type Profile struct {
+	// Provider is the model provider (e.g., anthropic, bedrock, or openai)
+	Provider string `yaml:"provider"`
+	// Endpoint is the provider's API endpoint (API_ENDPOINT)
+	Endpoint string `yaml:"endpoint"`
+	// Model is the default model string
+	Model string `yaml:"model"`
+	// Credentials are the keys used to authenticate to the provider (e.g., API_KEY or AWS_ROLE_ARN)
+	Credentials map[string]string `yaml:"credentials"`
+	// Defaults are any other keys, such as AWS_DEFAULT_REGION, resolved with Get
+	Defaults map[string]string `yaml:"defaults"`
+}
+
+// ProfilesFile is the format of the provider profiles file. JSON files are read the same way as YAML files.
+type ProfilesFile struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// profiles holds the parsed profiles file; it is read again when the file is modified
+var profiles = struct {
+	sync.Mutex
+	modified time.Time
+	file     ProfilesFile
+}{}
+
+// ProfilesFilePath returns the path to the provider profiles file
+func ProfilesFilePath() string {
+	if path := os.Getenv(ProfilesFileKey); path != "" {
+		return path
+	}
+	return filepath.Join(".", "profiles.yaml")
+}
+
+// Profiles returns the provider profiles from the profiles file. A file that does not exist is not an error.
+func Profiles() (map[string]Profile, error) {
+	path := ProfilesFilePath()
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("there was an error reading the profiles file %s: %w", path, err)
+	}
+
+	profiles.Lock()
+	defer profiles.Unlock()
+	if info.ModTime().Equal(profiles.modified) {
+		return profiles.file.Profiles, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("there was an error reading the profiles file %s: %w", path, err)
+	}
+	var file ProfilesFile
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("there was an error parsing the profiles file %s: %w", path, err)
+	}
+	profiles.file = file
+	profiles.modified = info.ModTime()
+	logging.LogInfo("Loaded provider profiles", "File", path, "Profiles", len(file.Profiles))
+	return file.Profiles, nil
+}
+
+// ProfileNames returns the sorted names of the provider profiles
+func ProfileNames() (names []string) {
+	all, err := Profiles()
+	if err != nil {
+		logging.LogError(err, "unable to list the provider profiles")
+		return
+	}
+	for name := range all {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return
+}
+
+// value returns the profile's value for the key
+func (p Profile) value(key string) (string, bool) {
+	switch key {
+	case "provider":
+		return p.Provider, p.Provider != ""
+	case "API_ENDPOINT":
+		if p.Endpoint != "" {
+			return p.Endpoint, true
+		}
+	case "model":
+		return p.Model, p.Model != ""
+	}
+	if v, ok := p.Credentials[key]; ok && v != "" {
+		return v, true
+	}
+	v, ok := p.Defaults[key]
+	return v, ok && v != ""
+}
+
+// CheckProfile returns why the named provider profile can not be used, like a profiles file that can not be parsed
+// or a profile that is not in it, or nil if it can be
+func CheckProfile(name string) error {
+	all, err := Profiles()
+	if err != nil {
+		return err
+	}
+	if _, found := all[name]; !found {
+		return fmt.Errorf("provider profile '%s' is not in the profiles file %s", name, ProfilesFilePath())
+	}
+	return nil
+}
+
+// reported holds the provider profile errors that were already logged so every lookup does not log them again
+var reported sync.Map
+
+// profileValue returns the key's value from the provider profile selected for the task.
+// The profile is selected with the ProfileKey, which is resolved without profiles, and ${KEY} references in the value
+// are expanded without profiles so a profile can not reference itself.
+// A profile that can not be used is logged once and skipped so the key falls back to the next source; the config
+// command reports it with CheckProfile.
+func profileValue(task *structs.PTTaskMessageAllData, key string) (value string, ok bool, err error) {
+	name, _, err := lookup(task, ProfileKey, false)
+	if err != nil || name == "" {
+		return "", false, nil
+	}
+	if err = CheckProfile(name); err != nil {
+		if _, logged := reported.LoadOrStore(err.Error(), true); !logged {
+			logging.LogError(err, "skipping the provider profile")
+		}
+		return "", false, nil
+	}
+	all, _ := Profiles()
+	value, ok = all[name].value(key)
+	if !ok {
+		return "", false, nil
+	}
+	value, err = expand(task, value, false)
+	if err != nil {
+		return "", false, fmt.Errorf("there was an error expanding the key %s from provider profile '%s': %w", key, name, err)
+	}
+	return value, true, nil
+}
@@ -0,0 +1,128 @@
+package mcp
+
+import (
+	// Standard
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+// Policy determines if the model can call an MCP tool on its own
+type Policy string
+
+const (
+	// Auto tools are called without operator oversight
+	Auto Policy = "auto"
+	// Confirm tools are only called after the operator approves the call
+	Confirm Policy = "confirm"
+	// Deny tools are never given to the model
+	Deny Policy = "deny"
+)
+
+// strictest returns the least permissive of the policies
+func strictest(policies ...Policy) Policy {
+	result := Auto
+	for _, p := range policies {
+		switch {
+		case p == Deny:
+			return Deny
+		case p == Confirm:
+			result = Confirm
+		}
+	}
+	return result
+}
+
+// ApprovalTimeout is how long a tool call waits for the operator before it is denied
+const ApprovalTimeout = 15 * time.Minute
+
+// ErrNoPendingApproval is returned when there is no tool call waiting for the operator's decision
+var ErrNoPendingApproval = errors.New("there is no tool call waiting for approval")
+
+// Decision is the operator's answer to a tool call that requires approval
+type Decision struct {
+	Approved bool
+	// Reason is an optional explanation from the operator that is given to the model
+	Reason string
+}
+
+// approvals are the tool calls waiting for an operator's decision, keyed by the task ID of the chat session
+var approvals = struct {
+	sync.Mutex
+	pending map[int]chan Decision
+}{pending: make(map[int]chan Decision)}
+
+// Pending returns true if a tool call in the chat session is waiting for the operator's decision
+func Pending(session int) bool {
+	approvals.Lock()
+	defer approvals.Unlock()
+	_, ok := approvals.pending[session]
+	return ok
+}
+
+// Resolve delivers the operator's decision to the tool call waiting in the chat session
+func Resolve(session int, decision Decision) error {
+	approvals.Lock()
+	defer approvals.Unlock()
+	c, ok := approvals.pending[session]
+	if !ok {
+		return ErrNoPendingApproval
+	}
+	delete(approvals.pending, session)
+	c <- decision
+	return nil
+}
+
+// requestApproval shows the proposed tool call in the chat session and blocks until the operator approves or denies it.
+// Calls that are not answered before the ApprovalTimeout are denied.
+func requestApproval(session int, name string, args map[string]interface{}) (decision Decision) {
+	c := make(chan Decision, 1)
+	approvals.Lock()
+	if _, ok := approvals.pending[session]; ok {
+		approvals.Unlock()
+		return Decision{Reason: "another tool call is already waiting for the operator's approval"}
+	}
+	approvals.pending[session] = c
+	approvals.Unlock()
+
+	arguments, err := json.MarshalIndent(args, "", "  ")
+	if err != nil {
+		arguments = []byte(fmt.Sprintf("%+v", args))
+	}
+	_, err = mythicrpc.SendMythicRPCResponseCreate(mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   session,
+		Response: []byte(fmt.Sprintf("✋ The model wants to call %s with the arguments:\n%s\nReply /approve or /deny <reason>\n👤> ", name, arguments)),
+	})
+	if err != nil {
+		logging.LogError(err, "there was an error asking the operator to approve the tool call", "session", session, "tool", name)
+	}
+
+	select {
+	case decision = <-c:
+	case <-time.After(ApprovalTimeout):
+		// Deny the call unless the operator's decision arrived at the same time
+		_ = Resolve(session, Decision{Reason: fmt.Sprintf("the operator did not respond within %s", ApprovalTimeout)})
+		decision = <-c
+	}
+	logging.LogInfo("MCP tool call approval", "session", session, "tool", name, "approved", decision.Approved, "reason", decision.Reason)
+	return
+}
+
+// session returns the task ID of the chat session the task belongs to, or 0 if the task can not be paused for approvals.
+// Chat is the only command with interactive tasking where the operator can answer.
+func session(task *structs.PTTaskMessageAllData) int {
+	if task.Task.IsInteractiveTask {
+		return task.Task.ParentTaskID
+	}
+	if task.Task.CommandName == "chat" {
+		return task.Task.ID
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package mcp
+
+import (
+	// Standard
+	"fmt"
+	"strings"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+
+	// 3rd Party
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Provider is a source of tools that runs inside the Sage container instead of behind an MCP server.
+// Its tools are given to the model alongside the tools of the connected MCP servers.
+type Provider interface {
+	// Alias is the prefix for the provider's tool names, like the alias of an MCP server
+	Alias() string
+	// Tools returns the provider's tools with their original names
+	Tools() []mcp.Tool
+	// CallTool calls the tool with its original name on behalf of the task
+	CallTool(task *structs.PTTaskMessageAllData, name string, args map[string]interface{}) (*mcp.CallToolResult, error)
+}
+
+// Guard is implemented by built-in providers with tools that are disabled unless the session enables them,
+// or that always require the operator's approval, regardless of the session's tool filter
+type Guard interface {
+	// Policy returns the least permissive policy allowed for the tool, by its original name, in the task's session
+	Policy(task *structs.PTTaskMessageAllData, name string) Policy
+}
+
+// providers are the registered built-in tool providers
+var providers []Provider
+
+// RegisterProvider adds the built-in tool provider so its tools are given to the model.
+// Providers must be registered before any MCP servers are connected so that their alias is reserved.
+func RegisterProvider(p Provider) {
+	providers = append(providers, p)
+	logging.LogInfo("Registered built-in tool provider", "Alias", p.Alias(), "Tools", len(p.Tools()))
+}
+
+// providerTools returns the tools from every built-in provider with their names prefixed by the provider's alias
+func providerTools() (tools []mcp.Tool) {
+	for _, p := range providers {
+		for _, tool := range p.Tools() {
+			tool.Name = toolName(p.Alias(), tool.Name)
+			tools = append(tools, tool)
+		}
+	}
+	return
+}
+
+// callProvider calls the tool if it belongs to a built-in provider. The returned bool is false if no provider has the tool.
+func callProvider(task *structs.PTTaskMessageAllData, name string, args map[string]interface{}) (*mcp.CallToolResult, bool, error) {
+	for _, p := range providers {
+		if !strings.HasPrefix(name, p.Alias()+toolSeparator) {
+			continue
+		}
+		for _, tool := range p.Tools() {
+			if toolName(p.Alias(), tool.Name) != name {
+				continue
+			}
+			logging.LogDebug("🚀 Calling built-in tool...", "Args", args, "Tool Name", name)
+			result, err := p.CallTool(task, tool.Name, args)
+			if err != nil {
+				err = fmt.Errorf("failed to call tool %s: %w", name, err)
+			}
+			return result, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// reservedAlias returns true if a built-in provider uses the alias
+func reservedAlias(alias string) bool {
+	for _, p := range providers {
+		if p.Alias() == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// providerPolicy returns the least permissive policy a built-in provider allows for the tool.
+// Tools from MCP servers and providers that are not a Guard are Auto.
+func providerPolicy(task *structs.PTTaskMessageAllData, name string) Policy {
+	for _, p := range providers {
+		guard, ok := p.(Guard)
+		if !ok || !strings.HasPrefix(name, p.Alias()+toolSeparator) {
+			continue
+		}
+		for _, tool := range p.Tools() {
+			if toolName(p.Alias(), tool.Name) == name {
+				return guard.Policy(task, tool.Name)
+			}
+		}
+	}
+	return Auto
+}
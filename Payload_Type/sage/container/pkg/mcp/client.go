@@ -1,11 +1,22 @@
 package mcp
 
 import (
+	// Standard
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"os"
+	"regexp"
+	"strings"
 	"time"
 
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
+
 	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
 	"github.com/MythicMeta/MythicContainer/logging"
 
 	// 3rd Party
@@ -14,18 +25,153 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
-var clients []MCPClient
+// ErrClientNotFound is returned when there is no connected MCP client with the requested ID
+var ErrClientNotFound = errors.New("MCP client not found")
+
+// Transport is the method used to communicate with an MCP server
+type Transport string
+
+const (
+	// Stdio starts the MCP server as a child process of the Sage container and communicates over stdin/stdout
+	Stdio Transport = "stdio"
+	// SSE connects to a remote MCP server using the HTTP with Server-Sent Events transport
+	SSE Transport = "sse"
+	// StreamableHTTP connects to a remote MCP server using the Streamable HTTP transport
+	StreamableHTTP Transport = "http"
+)
+
+// Transports returns a list of all the supported MCP transports as strings
+func Transports() []string {
+	return []string{string(Stdio), string(SSE), string(StreamableHTTP)}
+}
+
+// Server holds the information required to start, or connect to, an MCP server
+type Server struct {
+	Transport Transport         `json:"transport"`
+	Command   string            `json:"command,omitempty"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	Dir       string            `json:"cwd,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Alias     string            `json:"alias,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	// MaxConcurrency is the number of tool calls that run on the server at the same time; 0 uses DefaultMaxConcurrency
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// Timeout is the number of seconds a tool call can run on the server; 0 uses DefaultTimeout
+	Timeout int `json:"timeout,omitempty"`
+	// ToolTimeouts overrides Timeout for tools, by their original name, that need more or less time
+	ToolTimeouts map[string]int `json:"tool_timeouts,omitempty"`
+	// MaxResultBytes is the size of the largest tool result given to the model; 0 uses DefaultMaxResultBytes
+	MaxResultBytes int `json:"max_result_bytes,omitempty"`
+	// resolvedEnv and resolvedHeaders are Env and Headers with their ${KEY} references expanded by Resolve.
+	// They are not persisted so secrets resolved from user secrets never reach the state file.
+	resolvedEnv     map[string]string
+	resolvedHeaders map[string]string
+}
+
+// Resolve expands the ${KEY} references in the server's environment variables and headers with env.Expand.
+// Env and Headers keep the references, which is what is persisted, and the expanded values are only held in memory.
+func (s *Server) Resolve(task *structs.PTTaskMessageAllData) (err error) {
+	s.resolvedEnv = make(map[string]string)
+	for key, value := range s.Env {
+		s.resolvedEnv[key], err = env.Expand(task, value)
+		if err != nil {
+			return fmt.Errorf("there was an error resolving the value for the '%s' environment variable: %s", key, err)
+		}
+	}
+	s.resolvedHeaders = make(map[string]string)
+	for name, value := range s.Headers {
+		s.resolvedHeaders[name], err = env.Expand(task, value)
+		if err != nil {
+			return fmt.Errorf("there was an error resolving the value for the '%s' header: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// connect creates the MCP client for the server's transport.
+// Initialization of the MCP session is left to the caller.
+func (s Server) connect() (mcpClient client.MCPClient, err error) {
+	switch s.Transport {
+	case Stdio, "":
+		if s.Command == "" {
+			return nil, fmt.Errorf("a command is required for the %s transport", Stdio)
+		}
+		command, args := s.Command, s.Args
+		// The mcp-go stdio client does not expose the working directory, so let env change it before executing the command
+		if s.Dir != "" {
+			var info os.FileInfo
+			if info, err = os.Stat(s.Dir); err != nil || !info.IsDir() {
+				return nil, fmt.Errorf("the working directory '%s' is not a directory: %v", s.Dir, err)
+			}
+			command, args = "env", append([]string{"--chdir=" + s.Dir, "--", s.Command}, s.Args...)
+		}
+		// The environment variables are appended to the Sage container's environment
+		var environment []string
+		for k, v := range s.resolvedEnv {
+			environment = append(environment, k+"="+v)
+		}
+		mcpClient, err = client.NewStdioMCPClient(command, environment, args...)
+	case SSE:
+		if s.URL == "" {
+			return nil, fmt.Errorf("a URL is required for the %s transport", SSE)
+		}
+		var sseClient *client.SSEMCPClient
+		// The SSE read timeout applies to the lifetime of the event stream, not a single read
+		sseClient, err = client.NewSSEMCPClient(s.URL, client.WithHeaders(s.resolvedHeaders), client.WithSSEReadTimeout(24*time.Hour))
+		if err != nil {
+			break
+		}
+		// The event stream must outlive the task that created it, so it is not tied to a request context
+		err = sseClient.Start(context.Background())
+		mcpClient = sseClient
+	case StreamableHTTP:
+		if s.URL == "" {
+			return nil, fmt.Errorf("a URL is required for the %s transport", StreamableHTTP)
+		}
+		mcpClient, err = NewStreamableHTTPMCPClient(s.URL, s.resolvedHeaders)
+	default:
+		return nil, fmt.Errorf("unknown MCP transport '%s', expected one of: %s", s.Transport, strings.Join(Transports(), ", "))
+	}
+	return
+}
+
+// NewClient starts or connects to the MCP server, initializes the session, and adds the client to the registry
+func NewClient(server Server) (resp string, err error) {
+	// Generate a unique ID for the client
+	id := uuid.New()
+
+	mcpClient, resp, err := start(id, server)
+	if err != nil {
+		return
+	}
+
+	// Add the client to the registry, which makes its alias unique
+	mcpClient, err = registry.Add(mcpClient)
+	if err != nil {
+		mcpClient.Client.Close()
+		return
+	}
+	register(id, mcpClient.Server)
+	resp += mcpClient.describe()
+	return
+}
 
-func NewClient(command string, args []string) (resp string, err error) {
+// start creates the MCP client for the server, initializes the session, and lists the server's tools.
+// The client is not added to the registry; that is left to the caller.
+func start(id uuid.UUID, server Server) (c MCPClient, resp string, err error) {
 	// Create a new MCP client and connect to the MCP server
-	mcpClient, err := client.NewStdioMCPClient(command, []string{}, args...)
+	mcpClient, err := server.connect()
 	if err != nil {
-		return "", fmt.Errorf("failed to create MCP client: %w", err)
+		err = fmt.Errorf("failed to create MCP client: %w", err)
+		return
 	}
 	//defer mcpClient.Close()
 
-	// Generate a unique ID for the client
-	id := uuid.New()
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
 	resp += fmt.Sprintf("🎉 MCP client ID: %s\n\n", id.String())
 
 	// Initialize the request
@@ -37,69 +183,218 @@ func NewClient(command string, args []string) (resp string, err error) {
 		Version: "1.0.0",
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
 	// Initialize the client
 	initResult, err := mcpClient.Initialize(ctx, initRequest)
 	if err != nil {
 		err = fmt.Errorf("failed to initialize MCP client: %w", err)
+		mcpClient.Close()
 		return
 	}
 	logging.LogDebug("✅ MCP client initialized successfully!", "Server Name", initResult.ServerInfo.Name, "Server Version", initResult.ServerInfo.Version)
 	resp += fmt.Sprintf("MCP client initialized successfully with server: %s %s\n", initResult.ServerInfo.Name, initResult.ServerInfo.Version)
 
+	// Tools are namespaced with the server alias so that tools with the same name on different servers do not collide
+	if server.Alias == "" {
+		server.Alias = initResult.ServerInfo.Name
+	}
+	server.Alias = sanitizeAlias(server.Alias)
+
 	// Get the list of tools
 	toolsRequest := mcp.ListToolsRequest{}
 	tools, err := mcpClient.ListTools(ctx, toolsRequest)
 	if err != nil {
 		err = fmt.Errorf("😡 Failed to list tools: %w", err)
+		mcpClient.Close()
 		return
 	}
 
+	c = MCPClient{
+		ID:           id,
+		Server:       server,
+		Info:         initResult.ServerInfo,
+		Capabilities: initResult.Capabilities,
+		Client:       mcpClient,
+		Tools:        tools,
+		Connected:    time.Now().UTC(),
+	}
+	return
+}
+
+// describe returns the client's alias and tools for the operator
+func (c MCPClient) describe() (resp string) {
+	resp += fmt.Sprintf("Alias: %s\n", c.Server.Alias)
 	resp += "🛠️  Tools:\n"
-	for _, tool := range tools.Tools {
-		//fmt.Printf("\t- %s\n", tool.Name)
-		resp += fmt.Sprintf("- [TOOL]%s: %s\n", tool.Name, tool.Description)
-		//fmt.Printf("\tArguments: %s\n\n", tool.InputSchema.Properties)
+	for _, tool := range c.Tools.Tools {
+		resp += fmt.Sprintf("- [TOOL]%s: %s\n", c.ToolName(tool.Name), tool.Description)
+	}
+	return
+}
+
+// Clients returns a copy of the list of connected MCP clients
+func Clients() []MCPClient {
+	return registry.List()
+}
+
+// IDs returns the unique IDs of all connected MCP clients as strings
+func IDs() (ids []string) {
+	for _, c := range registry.List() {
+		ids = append(ids, c.ID.String())
 	}
+	return
+}
 
-	// Add the client to the list of clients
-	clients = append(clients, MCPClient{
-		ID:     id,
-		Client: mcpClient,
-		Tools:  tools,
+// Disconnect closes the MCP client with the provided ID, removes it from the registry, and stops persisting it.
+// For stdio servers, closing the client stops and reaps the child process.
+func Disconnect(id string) (err error) {
+	c, err := registry.Lookup(id)
+	if err != nil {
+		// A server that failed to start can still be removed from the pending servers and the state file
+		if p, ok := lookupPending(id); ok {
+			removePending(p.ID)
+			unregister(p.ID)
+			return nil
+		}
+		if uid, e := uuid.Parse(strings.TrimSpace(id)); e == nil && unregister(uid) {
+			return nil
+		}
+		return
+	}
+	unregister(c.ID)
+	if _, ok := registry.Remove(c.ID); !ok {
+		// Another task disconnected the client first and closed it
+		return nil
+	}
+
+	logging.LogDebug("🔌 Disconnecting MCP client", "ID", c.ID, "Server", c.Info.Name)
+	if err = c.Client.Close(); err != nil {
+		err = fmt.Errorf("there was an error closing MCP client %s: %w", c.ID, err)
+	}
+	return
+}
+
+// Restart starts the MCP client with the provided ID, or alias, again with the same server configuration and ID. The
+// server's ${KEY} references are resolved again for the task, so a rotated credential is picked up. The new client is
+// swapped into the registry before the old one is closed, and the old one is closed once the tool calls already running
+// on it finish. A pending server that could not be started when the container started is started for the first time.
+func Restart(task *structs.PTTaskMessageAllData, id string) (resp string, err error) {
+	current, err := registry.Lookup(id)
+	if err != nil {
+		var ok bool
+		if current, ok = registry.LookupAlias(strings.TrimSpace(id)); !ok {
+			if p, ok := lookupPending(id); ok {
+				return startPending(task, p)
+			}
+			return
+		}
+		err = nil
+	}
+
+	server := current.Server
+	if err = server.Resolve(task); err != nil {
+		return
+	}
+	c, resp, err := start(current.ID, server)
+	if err != nil {
+		err = fmt.Errorf("there was an error restarting MCP client %s, the running client was kept: %w", current.ID, err)
+		return
+	}
+	old, err := registry.Replace(c)
+	if err != nil {
+		// The client was disconnected while it was restarting
+		c.Client.Close()
+		return
+	}
+	old.calls.closeWhenIdle(func() {
+		// A failure to close is expected when the server already died
+		if e := old.Client.Close(); e != nil {
+			logging.LogDebug("there was an error closing the MCP client that was restarted", "ID", old.ID, "Error", e)
+		}
 	})
+	c, _ = registry.Lookup(c.ID.String())
+	resp += c.describe()
 	return
 }
 
-func GetAllTools() (tools []mcp.Tool) {
-	// Iterate over all clients and collect their tools
-	for _, client := range clients {
-		tools = append(tools, client.Tools.Tools...)
-		//fmt.Printf("[TOOL] Name: %s\n,Description: %s\n,InputSchema: %+v", client.Tools.Tools[0].Name, client.Tools.Tools[0].Description, client.Tools.Tools[0].InputSchema)
+// startPending resolves the pending server's references for the task, starts it, and adds it to the registry
+func startPending(task *structs.PTTaskMessageAllData, p PendingServer) (resp string, err error) {
+	server := p.Server
+	if err = server.Resolve(task); err != nil {
+		setPending(p.Registration, err)
+		return
 	}
+	c, resp, err := start(p.ID, server)
+	if err != nil {
+		setPending(p.Registration, err)
+		err = fmt.Errorf("there was an error starting MCP server %s: %w", p.ID, err)
+		return
+	}
+	if c, err = registry.Add(c); err != nil {
+		c.Client.Close()
+		return
+	}
+	removePending(p.ID)
+	resp += c.describe()
 	return
 }
 
-func ExecuteTool(toolName string, args map[string]interface{}) (resp string, err error) {
-	// Find the client with the specified tool name
-	var mcpClient *client.StdioMCPClient
-	for _, client := range clients {
-		for _, tool := range client.Tools.Tools {
-			if tool.Name == toolName {
-				mcpClient = client.Client
-				break
+// Status is the health of a single MCP client
+type Status struct {
+	Client MCPClient
+	Alive  bool
+	Error  error
+}
+
+// CheckStatus pings every MCP client and refreshes the cached list of tools for the servers that respond.
+// Clients that do not respond are closed, which reaps dead stdio child processes, and removed from the registry.
+func CheckStatus() (statuses []Status) {
+	for _, c := range registry.List() {
+		status := Status{Client: c}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		status.Error = c.Client.Ping(ctx)
+		if status.Error == nil {
+			var tools *mcp.ListToolsResult
+			tools, status.Error = c.Client.ListTools(ctx, mcp.ListToolsRequest{})
+			if status.Error == nil {
+				c.Tools = tools
+				status.Client = c
+				// A client restarted during the health check keeps its new connection
+				if e := registry.Refresh(c, tools); e != nil {
+					logging.LogDebug("the MCP client changed during its health check, its tools were not refreshed", "ID", c.ID, "Error", e)
+				}
 			}
 		}
-		if mcpClient != nil {
-			break
+		cancel()
+
+		if status.Error != nil {
+			logging.LogError(status.Error, "MCP client failed its health check, removing it", "ID", c.ID, "Server", c.Info.Name)
+			// Only close the connection if another task did not already disconnect or restart it
+			if registry.Discard(c) {
+				if err := c.Client.Close(); err != nil {
+					logging.LogDebug("there was an error closing the unhealthy MCP client", "ID", c.ID, "Error", err)
+				}
+			}
+		} else {
+			status.Alive = true
 		}
+		statuses = append(statuses, status)
 	}
+	return
+}
+
+// GetAllTools returns the built-in tools and the tools from every MCP server with their names prefixed by the server's
+// alias. Tools whose prefixed name is already used by another server are left out, like they are when the tool is called.
+func GetAllTools() (tools []mcp.Tool) {
+	return append(providerTools(), registry.Tools()...)
+}
 
-	if mcpClient == nil {
-		return "", fmt.Errorf("tool %s not found", toolName)
+// ExecuteTool calls the tool on the MCP server it belongs to and returns the server's result.
+// The name is the alias prefixed name returned by GetAllTools; the server is called with the tool's original name.
+func ExecuteTool(name string, args map[string]interface{}) (result *mcp.CallToolResult, err error) {
+	// Find the client with the specified tool name
+	c, original, ok := registry.LookupTool(name)
+	if !ok {
+		return nil, fmt.Errorf("tool %s not found", name)
 	}
 
 	// Create the MCP Tool Call Request
@@ -108,41 +403,95 @@ func ExecuteTool(toolName string, args map[string]interface{}) (resp string, err
 			Method: "tools/call",
 		},
 	}
-	fetchRequest.Params.Name = toolName
+	fetchRequest.Params.Name = original
 	fetchRequest.Params.Arguments = args
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	timeout := c.Server.CallTimeout(original)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	logging.LogDebug("🚀 Calling MCP tool...", "Args", args, "Tool Name", toolName)
-	result, err := mcpClient.CallTool(ctx, fetchRequest)
+	// Waiting for a busy server counts against the call's timeout
+	release, err := c.acquire(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to call tool %s: %w", toolName, err)
-	}
-
-	// Process the result
-	for _, r := range result.Content {
-		switch r.(type) {
-		case mcp.TextContent:
-			//logging.LogDebug("🛠️ MCP Tool Call Result", "Tool Name", toolName, "Result", r.(mcp.TextContent).Text)
-			resp += r.(mcp.TextContent).Text
-		case mcp.ImageContent:
-			err = fmt.Errorf("⚠️ Unhandled mcp.Content Result (ImageContent): %+v", r.(mcp.ImageContent))
-		case mcp.EmbeddedResource:
-			err = fmt.Errorf("⚠️ Unhandled mcp.Content Result (EmbeddedResource): %+v", r.(mcp.EmbeddedResource))
-		default:
-			err = fmt.Errorf("⚠️ Unhandled mcp.Content Result (%T): %+v", r, r)
-		}
+		return nil, fmt.Errorf("tool %s did not start: %w", name, err)
 	}
+	defer release()
 
-	//resp += fmt.Sprintf("🛠️ Tool Result: %s\n", result.Result)
-	return resp, nil
+	logging.LogDebug("🚀 Calling MCP tool...", "Args", args, "Tool Name", original)
+	result, err = c.Client.CallTool(ctx, fetchRequest)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("tool %s did not finish within %s", name, timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to call tool %s: %w", name, err)
+	}
+	return
 }
 
 type MCPClient struct {
-	ID     uuid.UUID
-	Client *client.StdioMCPClient
-	Tools  *mcp.ListToolsResult
+	ID           uuid.UUID
+	Server       Server
+	Info         mcp.Implementation
+	Capabilities mcp.ServerCapabilities
+	Client       client.MCPClient
+	Tools        *mcp.ListToolsResult
+	Connected    time.Time
+	// slots limits the number of tool calls that run on the server at the same time
+	slots chan struct{}
+	// calls tracks the tool calls running on this connection to the server
+	calls *calls
+}
+
+// ToolName returns the name the model uses for the server's tool, which is prefixed with the server's alias
+func (c MCPClient) ToolName(name string) string {
+	return toolName(c.Server.Alias, name)
+}
+
+// toolSeparator separates the server alias from the tool name
+const toolSeparator = "__"
+
+// maxToolName is the longest tool name that all the model providers accept
+const maxToolName = 64
+
+// toolName prefixes the tool name with the server alias, truncating it to the length model providers accept.
+// A truncated name ends with a short hash of the full name so two long tools that share a prefix do not collide.
+func toolName(alias, name string) string {
+	n := alias + toolSeparator + name
+	if len(n) > maxToolName {
+		sum := sha256.Sum256([]byte(n))
+		suffix := "_" + hex.EncodeToString(sum[:4])
+		n = n[:maxToolName-len(suffix)] + suffix
+	}
+	return n
+}
+
+// invalidAliasChars matches the characters that are not allowed in tool names by the model providers
+var invalidAliasChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// underscores matches runs of underscores, which are collapsed so an alias never contains the tool separator
+var underscores = regexp.MustCompile(`_{2,}`)
+
+// sanitizeAlias replaces the characters in the alias that model providers do not allow in tool names
+func sanitizeAlias(alias string) string {
+	alias = strings.Trim(invalidAliasChars.ReplaceAllString(strings.ToLower(alias), "_"), "_")
+	alias = underscores.ReplaceAllString(alias, "_")
+	if alias == "" {
+		alias = "mcp"
+	}
+	return alias
+}
+
+// Endpoint returns the command line for stdio servers or the URL for remote servers
+func (c MCPClient) Endpoint() string {
+	return c.Server.Endpoint()
+}
+
+// Endpoint returns the command line for stdio servers or the URL for remote servers
+func (s Server) Endpoint() string {
+	if s.Transport == Stdio || s.Transport == "" {
+		return strings.TrimSpace(s.Command + " " + strings.Join(s.Args, " "))
+	}
+	return s.URL
 }
 
 type ToolProperties struct {
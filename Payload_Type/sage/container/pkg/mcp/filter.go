@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	// Standard
+	"path"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+
+	// 3rd Party
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ToolFilter selects which MCP tools are given to the model for a chat session or query.
+// Entries are tool names as returned by GetAllTools or glob patterns (e.g., bloodhound__*).
+type ToolFilter struct {
+	// Allow is the list of tools the model can use; an empty list allows every tool
+	Allow []string `json:"allow"`
+	// Deny is the list of tools the model can not use; it takes precedence over Allow
+	Deny []string `json:"deny"`
+	// Confirm is the list of tools the operator must approve each call to before it runs
+	Confirm []string `json:"confirm"`
+}
+
+// NewToolFilter returns the tool filter from the "tools_allow", "tools_deny", and "tools_confirm" task arguments.
+// Commands that do not have the arguments get a filter that allows every tool.
+func NewToolFilter(task *structs.PTTaskMessageAllData) (filter ToolFilter) {
+	filter.Allow, _ = task.Args.GetChooseMultipleArg("tools_allow")
+	filter.Deny, _ = task.Args.GetChooseMultipleArg("tools_deny")
+	filter.Confirm, _ = task.Args.GetChooseMultipleArg("tools_confirm")
+	return
+}
+
+// Allowed returns true if the filter lets the model use the tool with the provided name
+func (f ToolFilter) Allowed(name string) bool {
+	if matchAny(f.Deny, name) {
+		return false
+	}
+	return len(f.Allow) == 0 || matchAny(f.Allow, name)
+}
+
+// Policy returns how the model is allowed to call the tool with the provided name
+func (f ToolFilter) Policy(name string) Policy {
+	if !f.Allowed(name) {
+		return Deny
+	}
+	if matchAny(f.Confirm, name) {
+		return Confirm
+	}
+	return Auto
+}
+
+// matchAny returns true if the name matches any of the tool names or glob patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == name {
+			return true
+		}
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTools returns the tools from every MCP server that the filter allows
+func (f ToolFilter) GetTools() (tools []mcp.Tool) {
+	for _, tool := range GetAllTools() {
+		if f.Allowed(tool.Name) {
+			tools = append(tools, tool)
+		}
+	}
+	return
+}
+
+// ToolChoices returns a glob pattern that matches all the tools for each built-in provider and MCP server followed by every tool name.
+// These are the choices operators pick from to build a tool filter.
+func ToolChoices() (choices []string) {
+	for _, p := range providers {
+		choices = append(choices, p.Alias()+toolSeparator+"*")
+	}
+	for _, c := range registry.List() {
+		choices = append(choices, c.Server.Alias+toolSeparator+"*")
+	}
+	for _, tool := range GetAllTools() {
+		choices = append(choices, tool.Name)
+	}
+	return
+}
@@ -0,0 +1,79 @@
+package mcp
+
+import (
+	// Standard
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+// DefaultTimeout is how long a tool call can run on an MCP server that does not set its own timeout
+const DefaultTimeout = time.Minute
+
+// DefaultMaxResultBytes is the size of the largest tool result given to the model, about 16,000 tokens, for MCP servers
+// and built-in tools that do not set their own limit
+const DefaultMaxResultBytes = 64 * 1024
+
+// CallTimeout returns how long a call to the tool, by its original name, can run on the server
+func (s Server) CallTimeout(tool string) time.Duration {
+	if seconds := s.ToolTimeouts[tool]; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if s.Timeout > 0 {
+		return time.Duration(s.Timeout) * time.Second
+	}
+	return DefaultTimeout
+}
+
+// ResultLimit returns the size, in bytes, of the largest tool result from the server that is given to the model
+func (s Server) ResultLimit() int {
+	if s.MaxResultBytes > 0 {
+		return s.MaxResultBytes
+	}
+	return DefaultMaxResultBytes
+}
+
+// resultLimit returns the result size limit for the alias prefixed tool name.
+// Built-in tools, which have no server, use DefaultMaxResultBytes.
+func resultLimit(name string) int {
+	if c, _, ok := registry.LookupTool(name); ok {
+		return c.Server.ResultLimit()
+	}
+	return DefaultMaxResultBytes
+}
+
+// truncate returns the tool's text result cut to the limit.
+// The full text is saved to Mythic as a file for the task, or the chat session for an interactive chat message, and the
+// model is told the file's name and ID so the operator, or the model with a file tool, can get the rest of the output.
+func truncate(task *structs.PTTaskMessageAllData, name, text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	// Do not split a multibyte character
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	notice := fmt.Sprintf("\n\n⚠️ The tool returned %d bytes, which is more than the %d byte limit, so only the beginning of the output is shown.", len(text), limit)
+
+	filename := name + "_output.txt"
+	resp, err := mythicrpc.SendMythicRPCFileCreate(mythicrpc.MythicRPCFileCreateMessage{
+		TaskID:       TaskID(task),
+		FileContents: []byte(text),
+		Filename:     filename,
+		Comment:      fmt.Sprintf("Full output of the MCP tool %s", name),
+	})
+	if err != nil || !resp.Success {
+		if err == nil {
+			err = fmt.Errorf("%s", resp.Error)
+		}
+		logging.LogError(err, "there was an error saving the oversized MCP tool result to Mythic", "Tool", name)
+		return text[:cut] + notice + fmt.Sprintf(" The full output could not be saved to Mythic: %s", err)
+	}
+	return text[:cut] + notice + fmt.Sprintf(" The full output was saved to Mythic as the file %s with ID %s", filename, resp.AgentFileId)
+}
@@ -0,0 +1,74 @@
+package mcp
+
+import (
+	// Standard
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+// RecordArg is the task argument with the kinds of model activity that are written back to Mythic
+const RecordArg = "record"
+
+const (
+	// RecordTags tags the task with the provider, model, and token usage
+	RecordTags = "tags"
+	// RecordComments adds a summary of the model's answer to the operation's event log
+	RecordComments = "comments"
+	// RecordArtifacts registers an artifact for every MCP tool call
+	RecordArtifacts = "artifacts"
+)
+
+// ArtifactType is the base artifact type of the artifacts registered for MCP tool calls
+const ArtifactType = "MCP Tool Call"
+
+// RecordChoices returns the kinds of model activity that can be written back to Mythic
+func RecordChoices() []string {
+	return []string{RecordTags, RecordComments, RecordArtifacts}
+}
+
+// Records returns true if the task's "record" argument includes the kind of model activity
+func Records(task *structs.PTTaskMessageAllData, kind string) bool {
+	kinds, _ := task.Args.GetChooseMultipleArg(RecordArg)
+	return slices.Contains(kinds, kind)
+}
+
+// TaskID returns the task the model's activity is recorded on.
+// Interactive chat messages are subtasks, so their activity belongs to the chat session.
+func TaskID(task *structs.PTTaskMessageAllData) int {
+	if task.Task.IsInteractiveTask {
+		return task.Task.ParentTaskID
+	}
+	return task.Task.ID
+}
+
+// recordToolCall registers an artifact with the tool, its server, and the model's arguments for the task
+func recordToolCall(task *structs.PTTaskMessageAllData, name string, args map[string]interface{}) {
+	alias, _, _ := strings.Cut(name, toolSeparator)
+	server := alias
+	if c, ok := registry.LookupAlias(alias); ok {
+		server = fmt.Sprintf("%s (%s)", alias, c.Endpoint())
+	}
+	input, err := json.Marshal(args)
+	if err != nil {
+		input = []byte(fmt.Sprintf("%v", args))
+	}
+
+	resp, err := mythicrpc.SendMythicRPCArtifactCreate(mythicrpc.MythicRPCArtifactCreateMessage{
+		TaskID:           TaskID(task),
+		ArtifactMessage:  fmt.Sprintf("%s %s on server %s", name, input, server),
+		BaseArtifactType: ArtifactType,
+	})
+	if err != nil || !resp.Success {
+		if err == nil {
+			err = fmt.Errorf("%s", resp.Error)
+		}
+		logging.LogError(err, "there was an error registering the MCP tool call artifact", "tool", name)
+	}
+}
@@ -0,0 +1,314 @@
+package mcp
+
+import (
+	// Standard
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	// Mythic
+	"github.com/MythicMeta/MythicContainer/logging"
+
+	// 3rd Party
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DefaultMaxConcurrency is the number of tool calls that can run at the same time on an MCP server that does not set
+// its own limit
+const DefaultMaxConcurrency = 4
+
+// Registry holds the connected MCP clients and indexes their tools by the alias prefixed name the model uses.
+// It is safe to use from concurrent Mythic tasks; clients are returned by value so callers never share the registry's state.
+type Registry struct {
+	mu sync.RWMutex
+	// clients are kept in the order they were connected
+	clients []MCPClient
+	// tools maps the alias prefixed tool name to the ID of the client with the tool and the tool's original name
+	tools map[string]toolRef
+}
+
+// toolRef locates a tool on an MCP client
+type toolRef struct {
+	ID   uuid.UUID
+	Name string
+}
+
+// registry is the list of MCP clients connected to the Sage container
+var registry = &Registry{tools: make(map[string]toolRef)}
+
+// Add adds the client to the registry and indexes its tools. If another client already uses the client's alias, a
+// numeric suffix is added; the client with its final alias is returned.
+func (r *Registry) Add(c MCPClient) (MCPClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.index(c.ID) >= 0 {
+		return c, fmt.Errorf("MCP client %s is already connected", c.ID)
+	}
+	c.Server.Alias = r.uniqueAlias(c.Server.Alias, c.ID)
+	c.slots = make(chan struct{}, c.Server.Concurrency())
+	c.calls = &calls{}
+	r.clients = append(r.clients, c)
+	r.reindex()
+	return c, nil
+}
+
+// Replace swaps the client with the same ID, like a restarted client, into the registry and returns the client it
+// replaced so the caller can close it. The client keeps its alias and concurrency limit.
+func (r *Registry) Replace(c MCPClient) (MCPClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(c.ID)
+	if i < 0 {
+		return MCPClient{}, fmt.Errorf("%w: %s", ErrClientNotFound, c.ID)
+	}
+	old := r.clients[i]
+	c.Server.Alias = old.Server.Alias
+	c.slots = old.slots
+	c.calls = &calls{}
+	r.clients[i] = c
+	r.reindex()
+	return old, nil
+}
+
+// Refresh updates the client's list of tools if the registry still holds the same connection to the server.
+// A client that was restarted or disconnected while its tools were being listed is left alone.
+func (r *Registry) Refresh(c MCPClient, tools *mcp.ListToolsResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(c.ID)
+	if i < 0 || r.clients[i].Client != c.Client {
+		return fmt.Errorf("%w: %s", ErrClientNotFound, c.ID)
+	}
+	r.clients[i].Tools = tools
+	r.reindex()
+	return nil
+}
+
+// Remove removes the client with the ID from the registry and returns it so the caller can close it
+func (r *Registry) Remove(id uuid.UUID) (MCPClient, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id)
+	if i < 0 {
+		return MCPClient{}, false
+	}
+	c := r.clients[i]
+	r.clients = append(r.clients[:i:i], r.clients[i+1:]...)
+	r.reindex()
+	return c, true
+}
+
+// Discard removes the client if the registry still holds the same connection to the server, like a client that failed
+// its health check, and reports whether it was removed. A client that was restarted or disconnected is left alone.
+func (r *Registry) Discard(c MCPClient) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(c.ID)
+	if i < 0 || r.clients[i].Client != c.Client {
+		return false
+	}
+	r.clients = append(r.clients[:i:i], r.clients[i+1:]...)
+	r.reindex()
+	return true
+}
+
+// Lookup returns the client with the provided ID string
+func (r *Registry) Lookup(id string) (MCPClient, error) {
+	uid, err := uuid.Parse(strings.TrimSpace(id))
+	if err != nil {
+		return MCPClient{}, fmt.Errorf("%w: '%s' is not a valid MCP client ID: %s", ErrClientNotFound, id, err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i := r.index(uid)
+	if i < 0 {
+		return MCPClient{}, fmt.Errorf("%w: %s", ErrClientNotFound, uid)
+	}
+	return r.clients[i], nil
+}
+
+// LookupAlias returns the client with the alias
+func (r *Registry) LookupAlias(alias string) (MCPClient, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.clients {
+		if c.Server.Alias == alias {
+			return c, true
+		}
+	}
+	return MCPClient{}, false
+}
+
+// LookupTool returns the client with the alias prefixed tool and the tool's original name on the MCP server
+func (r *Registry) LookupTool(name string) (c MCPClient, original string, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ref, ok := r.tools[name]
+	if !ok {
+		return
+	}
+	return r.clients[r.index(ref.ID)], ref.Name, true
+}
+
+// Tools returns the tools from every client, in the order the clients were connected, with their names prefixed by
+// the client's alias. A name used by more than one client is only returned for the client that owns it in the index,
+// so the model never gets two tools with the same name.
+func (r *Registry) Tools() (tools []mcp.Tool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.clients {
+		if c.Tools == nil {
+			continue
+		}
+		for _, tool := range c.Tools.Tools {
+			name := c.ToolName(tool.Name)
+			if ref, ok := r.tools[name]; !ok || ref.ID != c.ID || ref.Name != tool.Name {
+				continue
+			}
+			tool.Name = name
+			tools = append(tools, tool)
+		}
+	}
+	return
+}
+
+// List returns a copy of the connected clients in the order they were connected
+func (r *Registry) List() []MCPClient {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]MCPClient{}, r.clients...)
+}
+
+// index returns the position of the client with the ID, or -1. The caller must hold the lock.
+func (r *Registry) index(id uuid.UUID) int {
+	for i, c := range r.clients {
+		if c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// reindex rebuilds the tool name index. The first client with a tool name wins, like it did before the index existed.
+// The caller must hold the write lock.
+func (r *Registry) reindex() {
+	r.tools = make(map[string]toolRef)
+	for _, c := range r.clients {
+		if c.Tools == nil {
+			continue
+		}
+		for _, tool := range c.Tools.Tools {
+			name := c.ToolName(tool.Name)
+			if ref, ok := r.tools[name]; ok {
+				logging.LogError(fmt.Errorf("duplicate MCP tool name %s", name), "the tool is not available to the model", "Client", c.ID, "Tool", tool.Name, "Shadowed By", ref.Name)
+				continue
+			}
+			r.tools[name] = toolRef{ID: c.ID, Name: tool.Name}
+		}
+	}
+}
+
+// uniqueAlias returns the alias, with a numeric suffix if a built-in provider or another MCP client already uses it.
+// The caller must hold the lock.
+func (r *Registry) uniqueAlias(alias string, id uuid.UUID) string {
+	unique := alias
+	for i := 2; ; i++ {
+		taken := reservedAlias(unique)
+		for _, c := range r.clients {
+			if c.ID != id && c.Server.Alias == unique {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return unique
+		}
+		unique = fmt.Sprintf("%s_%d", alias, i)
+	}
+}
+
+// acquire waits for one of the client's concurrent call slots and returns the function that releases it.
+// It gives up when the context is done so a call waiting on a busy server still honors its timeout, and fails if the
+// client was replaced by a restart after the caller looked it up.
+func (c MCPClient) acquire(ctx context.Context) (release func(), err error) {
+	if c.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("MCP server %s is busy with %d other tool call(s): %w", c.Server.Alias, cap(c.slots), ctx.Err())
+	}
+	if c.calls != nil && !c.calls.start() {
+		<-c.slots
+		return nil, fmt.Errorf("MCP client %s was restarted, call the tool again", c.ID)
+	}
+	return func() {
+		c.calls.finish()
+		<-c.slots
+	}, nil
+}
+
+// calls counts the tool calls in flight on one connection to an MCP server so a connection that was replaced by a
+// restart is closed once its calls finish instead of out from under them
+type calls struct {
+	mu      sync.Mutex
+	running int
+	closing bool
+	onIdle  func()
+}
+
+// start records a new call and returns false if the connection is closing
+func (c *calls) start() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing {
+		return false
+	}
+	c.running++
+	return true
+}
+
+// finish records that a call returned and runs the close function if it was the last call on a closing connection
+func (c *calls) finish() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.running--
+	var onIdle func()
+	if c.running == 0 && c.onIdle != nil {
+		onIdle, c.onIdle = c.onIdle, nil
+	}
+	c.mu.Unlock()
+	if onIdle != nil {
+		onIdle()
+	}
+}
+
+// closeWhenIdle stops new calls and runs the close function now, or when the last running call finishes
+func (c *calls) closeWhenIdle(close func()) {
+	if c == nil {
+		close()
+		return
+	}
+	c.mu.Lock()
+	c.closing = true
+	if c.running > 0 {
+		c.onIdle = close
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+	close()
+}
+
+// Concurrency returns the number of tool calls the server runs at the same time
+func (s Server) Concurrency() int {
+	if s.MaxConcurrency > 0 {
+		return s.MaxConcurrency
+	}
+	return DefaultMaxConcurrency
+}
@@ -0,0 +1,274 @@
+package mcp
+
+import (
+	// Standard
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	// 3rd Party
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// newTestClient returns a client that is not connected to a server with tools that have the provided names
+func newTestClient(alias string, maxConcurrency int, tools ...string) MCPClient {
+	c := MCPClient{
+		ID:     uuid.New(),
+		Server: Server{Alias: alias, MaxConcurrency: maxConcurrency},
+		Tools:  &mcp.ListToolsResult{},
+	}
+	for _, name := range tools {
+		c.Tools.Tools = append(c.Tools.Tools, mcp.NewTool(name))
+	}
+	return c
+}
+
+// TestRegistryConcurrent connects, replaces, disconnects, and calls tools on clients from many goroutines.
+// Run it with go test -race to detect unsynchronized access to the registry.
+func TestRegistryConcurrent(t *testing.T) {
+	r := &Registry{tools: make(map[string]toolRef)}
+	const workers = 16
+	const iterations = 50
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				// Every client asks for the same alias so Add has to make them unique
+				c, err := r.Add(newTestClient("server", 2, "search", "read"))
+				if err != nil {
+					t.Errorf("Add returned an error: %s", err)
+					return
+				}
+				name := c.ToolName("search")
+				found, original, ok := r.LookupTool(name)
+				if !ok || found.ID != c.ID || original != "search" {
+					t.Errorf("LookupTool(%s) returned client %s tool %s %t, expected client %s tool search", name, found.ID, original, ok, c.ID)
+				}
+				release, err := found.acquire(context.Background())
+				if err != nil {
+					t.Errorf("acquire returned an error: %s", err)
+					return
+				}
+				release()
+
+				// A refreshed client has a new list of tools instead of changing the one in the registry
+				refreshed := newTestClient(c.Server.Alias, 2, "search", "read", "write")
+				refreshed.ID = c.ID
+				if _, err = r.Replace(refreshed); err != nil {
+					t.Errorf("Replace returned an error: %s", err)
+				}
+				r.List()
+				if _, ok = r.Remove(c.ID); !ok {
+					t.Errorf("Remove did not find client %s", c.ID)
+				}
+			}
+		}()
+	}
+
+	// Keep some clients connected while the others come and go so their aliases must stay unique
+	var kept []MCPClient
+	for i := 0; i < workers; i++ {
+		c, err := r.Add(newTestClient("server", 0, "search"))
+		if err != nil {
+			t.Fatalf("Add returned an error: %s", err)
+		}
+		kept = append(kept, c)
+	}
+	wg.Wait()
+
+	aliases := make(map[string]bool)
+	for _, c := range r.List() {
+		if aliases[c.Server.Alias] {
+			t.Errorf("alias %s is used by more than one client", c.Server.Alias)
+		}
+		aliases[c.Server.Alias] = true
+	}
+	if len(r.List()) != len(kept) {
+		t.Errorf("the registry has %d clients, expected %d", len(r.List()), len(kept))
+	}
+}
+
+// TestAcquireLimitsConcurrency checks that no more than the server's MaxConcurrency tool calls are in flight
+func TestAcquireLimitsConcurrency(t *testing.T) {
+	r := &Registry{tools: make(map[string]toolRef)}
+	c, err := r.Add(newTestClient("limited", 2, "slow"))
+	if err != nil {
+		t.Fatalf("Add returned an error: %s", err)
+	}
+
+	var inFlight, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := c.acquire(context.Background())
+			if err != nil {
+				t.Errorf("acquire returned an error: %s", err)
+				return
+			}
+			defer release()
+			n := inFlight.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			inFlight.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if peak.Load() > 2 {
+		t.Errorf("%d tool calls were in flight at the same time, expected at most 2", peak.Load())
+	}
+
+	// A call waiting on a busy server gives up when its context is done
+	first, _ := c.acquire(context.Background())
+	second, _ := c.acquire(context.Background())
+	defer first()
+	defer second()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err = c.acquire(ctx); err == nil {
+		t.Errorf("acquire on a busy server returned without an error")
+	}
+}
+
+// TestReindexDuplicateNames checks that a tool name used by two clients always resolves to the first client connected
+func TestReindexDuplicateNames(t *testing.T) {
+	// "x" + "y__z" and "x__y" + "z" both become x__y__z
+	first := newTestClient("x", 0, "y__z")
+	second := newTestClient("x__y", 0, "z")
+
+	r := &Registry{tools: make(map[string]toolRef)}
+	if _, err := r.Add(first); err != nil {
+		t.Fatalf("Add returned an error: %s", err)
+	}
+	if _, err := r.Add(second); err != nil {
+		t.Fatalf("Add returned an error: %s", err)
+	}
+	for i := 0; i < 100; i++ {
+		// Replace reindexes every client
+		if _, err := r.Replace(second); err != nil {
+			t.Fatalf("Replace returned an error: %s", err)
+		}
+		c, original, ok := r.LookupTool("x__y__z")
+		if !ok || c.ID != first.ID || original != "y__z" {
+			t.Fatalf("LookupTool returned client %s tool %s on reindex %d, expected the first client %s", c.ID, original, i, first.ID)
+		}
+	}
+
+	// The model is only given the tool that LookupTool calls
+	names := 0
+	for _, tool := range r.Tools() {
+		if tool.Name == "x__y__z" {
+			names++
+		}
+	}
+	if names != 1 {
+		t.Errorf("Tools returned %d tools named x__y__z, expected 1", names)
+	}
+
+	// Disconnecting the first client makes the other tool available
+	r.Remove(first.ID)
+	if c, original, ok := r.LookupTool("x__y__z"); !ok || c.ID != second.ID || original != "z" {
+		t.Errorf("LookupTool returned client %s tool %s after the first client was removed, expected %s", c.ID, original, second.ID)
+	}
+}
+
+// TestToolNameTruncation checks that long tool names that share a prefix are still unique
+func TestToolNameTruncation(t *testing.T) {
+	prefix := strings.Repeat("a", maxToolName)
+	one := toolName("server", prefix+"_one")
+	two := toolName("server", prefix+"_two")
+	for _, name := range []string{one, two} {
+		if len(name) > maxToolName {
+			t.Errorf("tool name %s is %d characters, expected at most %d", name, len(name), maxToolName)
+		}
+	}
+	if one == two {
+		t.Errorf("truncated tool names collided: %s", one)
+	}
+	if one != toolName("server", prefix+"_one") {
+		t.Errorf("truncated tool names are not stable")
+	}
+	if short := toolName("server", "search"); short != fmt.Sprintf("server%ssearch", toolSeparator) {
+		t.Errorf("short tool name was changed to %s", short)
+	}
+}
+
+// TestReplaceClosesWhenIdle checks that a restarted client's old connection is only closed after its running calls finish
+func TestReplaceClosesWhenIdle(t *testing.T) {
+	r := &Registry{tools: make(map[string]toolRef)}
+	c, err := r.Add(newTestClient("restarted", 0, "slow"))
+	if err != nil {
+		t.Fatalf("Add returned an error: %s", err)
+	}
+	release, err := c.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire returned an error: %s", err)
+	}
+
+	replacement := newTestClient("restarted", 0, "slow")
+	replacement.ID = c.ID
+	old, err := r.Replace(replacement)
+	if err != nil {
+		t.Fatalf("Replace returned an error: %s", err)
+	}
+	var closed atomic.Bool
+	old.calls.closeWhenIdle(func() { closed.Store(true) })
+	if closed.Load() {
+		t.Fatalf("the old connection was closed while a call was running on it")
+	}
+	if _, err = c.acquire(context.Background()); err == nil {
+		t.Errorf("a new call started on the old connection after it was replaced")
+	}
+	release()
+	if !closed.Load() {
+		t.Errorf("the old connection was not closed after its last call finished")
+	}
+
+	current, _, _ := r.LookupTool(c.ToolName("slow"))
+	if release, err = current.acquire(context.Background()); err != nil {
+		t.Errorf("acquire on the new connection returned an error: %s", err)
+	} else {
+		release()
+	}
+}
+
+// TestSanitizeAlias checks that an alias never contains the tool separator, which would split tool names in the wrong place
+func TestSanitizeAlias(t *testing.T) {
+	tests := map[string]string{
+		"a___b":         "a_b",
+		"a__b":          "a_b",
+		"a____b__c":     "a_b_c",
+		"My Server!":    "my_server",
+		"__leading":     "leading",
+		"trailing___":   "trailing",
+		"dots.and/path": "dots_and_path",
+		"***":           "mcp",
+	}
+	for alias, expected := range tests {
+		got := sanitizeAlias(alias)
+		if got != expected {
+			t.Errorf("sanitizeAlias(%q) returned %q, expected %q", alias, got, expected)
+		}
+		if strings.Contains(got, toolSeparator) {
+			t.Errorf("sanitizeAlias(%q) returned %q, which contains the tool separator", alias, got)
+		}
+		if prefix, _, _ := strings.Cut(toolName(got, "tool__name"), toolSeparator); prefix != got {
+			t.Errorf("the tool name for alias %q was split at %q", got, prefix)
+		}
+	}
+}
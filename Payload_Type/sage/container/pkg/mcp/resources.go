@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	// Standard
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/message"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+
+	// 3rd Party
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Resources are the resources and resource templates an MCP server exposes
+type Resources struct {
+	Resources []mcp.Resource
+	Templates []mcp.ResourceTemplate
+}
+
+// ListResources returns the resources and resource templates from the MCP client with the provided ID
+func ListResources(id string) (resources Resources, err error) {
+	c, err := registry.Lookup(id)
+	if err != nil {
+		return
+	}
+	if c.Capabilities.Resources == nil {
+		return resources, fmt.Errorf("MCP server %s (%s) does not support resources", c.Info.Name, c.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	result, err := c.Client.ListResources(ctx, mcp.ListResourcesRequest{})
+	if err != nil {
+		return resources, fmt.Errorf("failed to list resources for MCP server %s: %w", c.ID, err)
+	}
+	resources.Resources = result.Resources
+
+	// Resource templates are optional so servers that do not implement them are not an error
+	templates, err := c.Client.ListResourceTemplates(ctx, mcp.ListResourceTemplatesRequest{})
+	if err != nil {
+		logging.LogDebug("there was an error listing the MCP resource templates", "ID", c.ID, "Error", err)
+		return resources, nil
+	}
+	resources.Templates = templates.ResourceTemplates
+	return
+}
+
+// ReadResource reads the resource with the provided URI from the MCP client with the provided ID.
+// URIs for resource templates must be expanded by the caller.
+func ReadResource(id, uri string) (result *mcp.ReadResourceResult, err error) {
+	c, err := registry.Lookup(id)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = uri
+	logging.LogDebug("📖 Reading MCP resource...", "ID", c.ID, "URI", uri)
+	result, err = c.Client.ReadResource(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource %s from MCP server %s: %w", uri, c.ID, err)
+	}
+	return
+}
+
+// ListPrompts returns the prompts from the MCP client with the provided ID
+func ListPrompts(id string) (prompts []mcp.Prompt, err error) {
+	c, err := registry.Lookup(id)
+	if err != nil {
+		return
+	}
+	return c.listPrompts()
+}
+
+// listPrompts returns the server's prompts or an error if the server does not support prompts
+func (c MCPClient) listPrompts() (prompts []mcp.Prompt, err error) {
+	if c.Capabilities.Prompts == nil {
+		return nil, fmt.Errorf("MCP server %s (%s) does not support prompts", c.Info.Name, c.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	result, err := c.Client.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompts for MCP server %s: %w", c.ID, err)
+	}
+	return result.Prompts, nil
+}
+
+// PromptChoices returns the name of every prompt from the MCP servers that support prompts, prefixed with the server's alias.
+// Servers that fail to list their prompts are skipped.
+func PromptChoices() (choices []string) {
+	for _, c := range registry.List() {
+		if c.Capabilities.Prompts == nil {
+			continue
+		}
+		prompts, err := c.listPrompts()
+		if err != nil {
+			logging.LogError(err, "skipping the MCP server's prompts")
+			continue
+		}
+		for _, prompt := range prompts {
+			choices = append(choices, c.Server.Alias+toolSeparator+prompt.Name)
+		}
+	}
+	return
+}
+
+// GetPrompt renders the prompt with the provided arguments.
+// The name is the alias prefixed name returned by PromptChoices; the server is called with the prompt's original name.
+func GetPrompt(name string, args map[string]string) (result *mcp.GetPromptResult, err error) {
+	// Aliases never contain the separator so the first one splits the alias from the prompt name
+	alias, original, ok := strings.Cut(name, toolSeparator)
+	if !ok {
+		return nil, fmt.Errorf("prompt %s is not prefixed with an MCP server alias", name)
+	}
+
+	mcpClient, ok := registry.LookupAlias(alias)
+	if !ok {
+		return nil, fmt.Errorf("%w: no MCP server has the alias %s", ErrClientNotFound, alias)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	request := mcp.GetPromptRequest{}
+	request.Params.Name = original
+	request.Params.Arguments = args
+	logging.LogDebug("🚀 Getting MCP prompt...", "ID", mcpClient.ID, "Prompt", original, "Args", args)
+	result, err = mcpClient.Client.GetPrompt(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt %s: %w", name, err)
+	}
+	return
+}
+
+// PromptMessages converts the messages of a rendered MCP prompt into Sage messages.
+// Embedded resources are inlined, or saved to Mythic if they are binary, because Sage messages only contain text.
+func PromptMessages(task *structs.PTTaskMessageAllData, prompt *mcp.GetPromptResult) (messages []message.Message) {
+	for _, m := range prompt.Messages {
+		msg := message.Message{Role: message.User}
+		if m.Role == mcp.RoleAssistant {
+			msg.Role = message.Assistant
+		}
+		switch c := m.Content.(type) {
+		case mcp.TextContent:
+			msg.Content = c.Text
+		case mcp.EmbeddedResource:
+			msg.Content = ResourceText(task, c.Resource)
+		case mcp.ImageContent:
+			msg.Content = fmt.Sprintf("⚠️ The prompt contained a %s image that Sage can not send to the model", c.MIMEType)
+		default:
+			msg.Content = fmt.Sprintf("⚠️ The prompt contained an unsupported content type (%T) that Sage can not process", c)
+		}
+		messages = append(messages, msg)
+	}
+	return
+}
@@ -0,0 +1,218 @@
+package mcp
+
+import (
+	// Standard
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+
+	// 3rd Party
+	"github.com/google/uuid"
+)
+
+const (
+	// ConfigFileKey is the environment variable that overrides the location of the MCP server configuration file
+	ConfigFileKey = "MCP_CONFIG_FILE"
+	// StateFileKey is the environment variable that overrides the location of the file used to persist MCP servers
+	StateFileKey = "MCP_STATE_FILE"
+)
+
+// registrations are the MCP servers connected with mcp-connect that are persisted to the state file.
+// A registration outlives its client so that a server that is down when the container starts is not forgotten.
+var registrations []Registration
+
+// storeMu guards registrations and serializes writes to the state file so concurrent mcp-connect, mcp-disconnect,
+// and mcp-restart tasks can not lose a registration or interleave their writes
+var storeMu sync.Mutex
+
+// pending are the MCP servers from the configuration and state files that could not be started when the container
+// started, like a server whose ${KEY} references come from a user secret that is only sent with tasks. They are
+// started with mcp-restart, which resolves the references for the operator's task. Guarded by storeMu.
+var pending []PendingServer
+
+// Registration is an MCP server that is connected when the Sage container starts
+type Registration struct {
+	ID uuid.UUID `json:"id"`
+	Server
+}
+
+// PendingServer is an MCP server that could not be started when the container started and why
+type PendingServer struct {
+	Registration
+	Error error
+}
+
+// File is the format of both the MCP server configuration file and the state file
+type File struct {
+	Servers []Registration `json:"servers"`
+}
+
+// ConfigFile returns the path to the read-only configuration file operators use to pre-declare MCP servers
+func ConfigFile() string {
+	if path := os.Getenv(ConfigFileKey); path != "" {
+		return path
+	}
+	return filepath.Join(".", "mcp.json")
+}
+
+// StateFile returns the path to the file where MCP servers connected with mcp-connect are persisted
+func StateFile() string {
+	if path := os.Getenv(StateFileKey); path != "" {
+		return path
+	}
+	return filepath.Join(".", "mcp_state.json")
+}
+
+// readFile reads the MCP server file at the provided path. A file that does not exist is not an error.
+func readFile(path string) (f File, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return f, fmt.Errorf("there was an error reading the MCP server file %s: %w", path, err)
+	}
+	if err = json.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("there was an error parsing the MCP server file %s: %w", path, err)
+	}
+	return
+}
+
+// save writes the registered MCP servers to the state file. The caller must hold storeMu.
+// ${KEY} references are saved unexpanded, but values typed on the command line are saved as they were given, so the
+// file is only readable by the container user.
+// It is written to a temporary file and renamed so a crash never leaves a partial state file.
+func save() error {
+	data, err := json.MarshalIndent(File{Servers: registrations}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("there was an error marshalling the MCP servers: %w", err)
+	}
+	tmp := StateFile() + ".tmp"
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("there was an error writing the MCP state file %s: %w", tmp, err)
+	}
+	if err = os.Rename(tmp, StateFile()); err != nil {
+		return fmt.Errorf("there was an error writing the MCP state file %s: %w", StateFile(), err)
+	}
+	return nil
+}
+
+// register persists the MCP server so that it is reconnected when the Sage container restarts
+func register(id uuid.UUID, server Server) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	registrations = append(registrations, Registration{ID: id, Server: server})
+	if err := save(); err != nil {
+		logging.LogError(err, "the MCP server will not be reconnected when the container restarts", "ID", id)
+	}
+}
+
+// unregister removes the MCP server from the state file and returns true if it was registered
+func unregister(id uuid.UUID) bool {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	i := slices.IndexFunc(registrations, func(r Registration) bool { return r.ID == id })
+	if i < 0 {
+		return false
+	}
+	registrations = slices.Delete(registrations, i, i+1)
+	if err := save(); err != nil {
+		logging.LogError(err, "the MCP server will be reconnected when the container restarts", "ID", id)
+	}
+	return true
+}
+
+// PendingServers returns the MCP servers that could not be started when the container started
+func PendingServers() []PendingServer {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	return append([]PendingServer{}, pending...)
+}
+
+// lookupPending returns the pending MCP server with the ID or alias
+func lookupPending(id string) (PendingServer, bool) {
+	id = strings.TrimSpace(id)
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	i := slices.IndexFunc(pending, func(p PendingServer) bool { return p.ID.String() == id || p.Server.Alias == id })
+	if i < 0 {
+		return PendingServer{}, false
+	}
+	return pending[i], true
+}
+
+// setPending records that the MCP server could not be started, replacing any earlier error for the same server
+func setPending(r Registration, err error) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	pending = slices.DeleteFunc(pending, func(p PendingServer) bool { return p.ID == r.ID })
+	pending = append(pending, PendingServer{Registration: r, Error: err})
+}
+
+// removePending removes the MCP server from the pending servers and returns true if it was pending
+func removePending(id uuid.UUID) bool {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	n := len(pending)
+	pending = slices.DeleteFunc(pending, func(p PendingServer) bool { return p.ID == id })
+	return len(pending) != n
+}
+
+// Reconnect starts, or connects to, every MCP server declared in the configuration file and persisted in the state file.
+// Servers that fail to start are logged and kept as pending servers, which are listed by mcp-list and started with
+// mcp-restart; persisted servers stay registered so they are also tried again on the next start.
+func Reconnect() error {
+	var errs []error
+	config, err := readFile(ConfigFile())
+	if err != nil {
+		errs = append(errs, err)
+	}
+	state, err := readFile(StateFile())
+	if err != nil {
+		errs = append(errs, err)
+	}
+	storeMu.Lock()
+	registrations = append([]Registration{}, state.Servers...)
+	storeMu.Unlock()
+
+	for _, r := range append(config.Servers, state.Servers...) {
+		// Servers in the configuration file are not required to have an ID, so give them one that is stable across restarts
+		if r.ID == uuid.Nil {
+			r.ID = uuid.NewSHA1(uuid.NameSpaceURL, []byte(string(r.Transport)+" "+r.Server.Endpoint()))
+		}
+		if _, e := registry.Lookup(r.ID.String()); e == nil {
+			logging.LogDebug("skipping duplicate MCP server", "ID", r.ID)
+			continue
+		}
+
+		// User secrets are only sent with tasks, so references are resolved from the container's environment.
+		// A server that needs a user secret, a task argument, or a provider profile waits for mcp-restart.
+		if e := r.Server.Resolve(&structs.PTTaskMessageAllData{}); e != nil {
+			setPending(r, e)
+			errs = append(errs, fmt.Errorf("there was an error starting MCP server %s (%s), start it with mcp-restart: %w", r.ID, r.Server.Endpoint(), e))
+			continue
+		}
+		c, _, e := start(r.ID, r.Server)
+		if e != nil {
+			setPending(r, e)
+			errs = append(errs, fmt.Errorf("there was an error starting MCP server %s (%s), start it with mcp-restart: %w", r.ID, r.Server.Endpoint(), e))
+			continue
+		}
+		if c, e = registry.Add(c); e != nil {
+			c.Client.Close()
+			errs = append(errs, e)
+			continue
+		}
+		logging.LogInfo("Connected to MCP server", "ID", c.ID, "Server", c.Info.Name, "Tools", len(c.Tools.Tools))
+	}
+	return errors.Join(errs...)
+}
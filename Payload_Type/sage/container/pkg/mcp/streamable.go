@@ -0,0 +1,440 @@
+package mcp
+
+import (
+	// Standard
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	// Mythic
+	"github.com/MythicMeta/MythicContainer/logging"
+
+	// 3rd Party
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// sessionHeader is the HTTP header a Streamable HTTP MCP server uses to identify a session
+const sessionHeader = "Mcp-Session-Id"
+
+// connectTimeout is how long connecting to the server, including the TLS handshake, can take. Requests are otherwise
+// bounded by their context because a tool call's response can stream for as long as the tool's timeout.
+const connectTimeout = 30 * time.Second
+
+// closeTimeout is how long the server has to end the session when the client is closed
+const closeTimeout = 10 * time.Second
+
+// StreamableHTTPMCPClient implements the client.MCPClient interface using the MCP Streamable HTTP transport.
+// Every JSON-RPC message is sent as an HTTP POST to a single endpoint and the server answers with either a
+// single JSON object or a text/event-stream that carries the response along with any server notifications.
+// https://modelcontextprotocol.io/specification/2025-03-26/basic/transports#streamable-http
+type StreamableHTTPMCPClient struct {
+	endpoint      *url.URL
+	httpClient    *http.Client
+	headers       map[string]string
+	requestID     atomic.Int64
+	sessionID     string
+	mu            sync.RWMutex
+	initialized   atomic.Bool
+	notifications []func(mcp.JSONRPCNotification)
+	notifyMu      sync.RWMutex
+	capabilities  mcp.ServerCapabilities
+}
+
+// NewStreamableHTTPMCPClient creates a new Streamable HTTP MCP client for the provided endpoint URL.
+// The headers are added to every request sent to the server (e.g., Authorization)
+func NewStreamableHTTPMCPClient(endpoint string, headers map[string]string) (*StreamableHTTPMCPClient, error) {
+	parsedURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	return &StreamableHTTPMCPClient{
+		endpoint: parsedURL,
+		httpClient: &http.Client{Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: connectTimeout}).DialContext,
+			TLSHandshakeTimeout:   connectTimeout,
+			IdleConnTimeout:       90 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		}},
+		headers: headers,
+	}, nil
+}
+
+// rpcMessage is the union of the JSON-RPC response and notification fields returned by the server
+type rpcMessage struct {
+	JSONRPC string `json:"jsonrpc"`
+	// ID is kept raw because JSON-RPC allows string IDs, which some servers use for their own requests
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// post sends the JSON-RPC message body to the server endpoint and returns the HTTP response
+func (c *StreamableHTTPMCPClient) post(ctx context.Context, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	c.mu.RLock()
+	if c.sessionID != "" {
+		req.Header.Set(sessionHeader, c.sessionID)
+	}
+	c.mu.RUnlock()
+
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, data)
+	}
+
+	if id := resp.Header.Get(sessionHeader); id != "" {
+		c.mu.Lock()
+		c.sessionID = id
+		c.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// sendRequest sends a JSON-RPC request to the server and waits for the matching response
+func (c *StreamableHTTPMCPClient) sendRequest(ctx context.Context, method string, params interface{}) (*json.RawMessage, error) {
+	if !c.initialized.Load() && method != "initialize" {
+		return nil, fmt.Errorf("client not initialized")
+	}
+
+	id := c.requestID.Add(1)
+	request := mcp.JSONRPCRequest{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      id,
+		Request: mcp.Request{
+			Method: method,
+		},
+		Params: params,
+	}
+
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := c.post(ctx, requestBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		var data []byte
+		data, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		var result *json.RawMessage
+		result, err = c.handleMessage(id, data)
+		if result == nil && err == nil {
+			err = fmt.Errorf("the server did not return a response to request %d", id)
+		}
+		return result, err
+	case "text/event-stream":
+		return c.readStream(ctx, id, resp.Body)
+	default:
+		return nil, fmt.Errorf("unexpected response content type: %q", resp.Header.Get("Content-Type"))
+	}
+}
+
+// readStream reads server-sent events until the response for the request ID is received.
+// Notifications that arrive on the stream before the response are dispatched to the registered handlers.
+func (c *StreamableHTTPMCPClient) readStream(ctx context.Context, id int64, body io.Reader) (*json.RawMessage, error) {
+	br := bufio.NewReader(body)
+	var data strings.Builder
+	for {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read event stream: %w", err)
+		}
+		eof := errors.Is(err, io.EOF)
+
+		line = strings.TrimRight(line, "\r\n")
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			// The lines of a multi-line data field are joined with a newline and lose one leading space
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(value, " "))
+		}
+
+		// An empty line (or the end of the stream) dispatches the event
+		if (line == "" || eof) && data.Len() > 0 {
+			result, err := c.handleMessage(id, []byte(data.String()))
+			if result != nil || err != nil {
+				return result, err
+			}
+			data.Reset()
+		}
+
+		if eof {
+			return nil, fmt.Errorf("event stream closed before a response to request %d was received", id)
+		}
+	}
+}
+
+// handleMessage returns the result for a response that matches the request ID.
+// Notifications are dispatched to the registered handlers and return a nil result and nil error.
+func (c *StreamableHTTPMCPClient) handleMessage(id int64, data []byte) (*json.RawMessage, error) {
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		logging.LogError(err, "failed to unmarshal MCP message", "data", string(data))
+		return nil, nil
+	}
+	if len(msg.ID) == 0 || bytes.Equal(msg.ID, []byte("null")) {
+		if msg.Method == "" {
+			return nil, nil
+		}
+		var notification mcp.JSONRPCNotification
+		if err := json.Unmarshal(data, &notification); err != nil {
+			return nil, nil
+		}
+		c.notifyMu.RLock()
+		for _, handler := range c.notifications {
+			handler(notification)
+		}
+		c.notifyMu.RUnlock()
+		return nil, nil
+	}
+	if !bytes.Equal(msg.ID, []byte(strconv.FormatInt(id, 10))) {
+		return nil, nil
+	}
+	if msg.Error != nil {
+		return nil, errors.New(msg.Error.Message)
+	}
+	return &msg.Result, nil
+}
+
+// sendNotification sends a JSON-RPC notification to the server, which does not have a response
+func (c *StreamableHTTPMCPClient) sendNotification(ctx context.Context, method string) error {
+	notification := mcp.JSONRPCNotification{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{
+			Method: method,
+		},
+	}
+	notificationBytes, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s notification: %w", method, err)
+	}
+	resp, err := c.post(ctx, notificationBytes)
+	if err != nil {
+		return fmt.Errorf("failed to send %s notification: %w", method, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *StreamableHTTPMCPClient) Initialize(ctx context.Context, request mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+	// Ensure we send a params object with all required fields
+	params := struct {
+		ProtocolVersion string                 `json:"protocolVersion"`
+		ClientInfo      mcp.Implementation     `json:"clientInfo"`
+		Capabilities    mcp.ClientCapabilities `json:"capabilities"`
+	}{
+		ProtocolVersion: request.Params.ProtocolVersion,
+		ClientInfo:      request.Params.ClientInfo,
+		Capabilities:    request.Params.Capabilities,
+	}
+
+	response, err := c.sendRequest(ctx, "initialize", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result mcp.InitializeResult
+	if err = json.Unmarshal(*response, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	c.capabilities = result.Capabilities
+
+	if err = c.sendNotification(ctx, "notifications/initialized"); err != nil {
+		return nil, err
+	}
+
+	c.initialized.Store(true)
+	return &result, nil
+}
+
+func (c *StreamableHTTPMCPClient) Ping(ctx context.Context) error {
+	_, err := c.sendRequest(ctx, "ping", nil)
+	return err
+}
+
+func (c *StreamableHTTPMCPClient) ListResources(ctx context.Context, request mcp.ListResourcesRequest) (*mcp.ListResourcesResult, error) {
+	response, err := c.sendRequest(ctx, "resources/list", request.Params)
+	if err != nil {
+		return nil, err
+	}
+	var result mcp.ListResourcesResult
+	if err = json.Unmarshal(*response, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *StreamableHTTPMCPClient) ListResourceTemplates(ctx context.Context, request mcp.ListResourceTemplatesRequest) (*mcp.ListResourceTemplatesResult, error) {
+	response, err := c.sendRequest(ctx, "resources/templates/list", request.Params)
+	if err != nil {
+		return nil, err
+	}
+	var result mcp.ListResourceTemplatesResult
+	if err = json.Unmarshal(*response, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *StreamableHTTPMCPClient) ReadResource(ctx context.Context, request mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	response, err := c.sendRequest(ctx, "resources/read", request.Params)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.ParseReadResourceResult(response)
+}
+
+func (c *StreamableHTTPMCPClient) Subscribe(ctx context.Context, request mcp.SubscribeRequest) error {
+	_, err := c.sendRequest(ctx, "resources/subscribe", request.Params)
+	return err
+}
+
+func (c *StreamableHTTPMCPClient) Unsubscribe(ctx context.Context, request mcp.UnsubscribeRequest) error {
+	_, err := c.sendRequest(ctx, "resources/unsubscribe", request.Params)
+	return err
+}
+
+func (c *StreamableHTTPMCPClient) ListPrompts(ctx context.Context, request mcp.ListPromptsRequest) (*mcp.ListPromptsResult, error) {
+	response, err := c.sendRequest(ctx, "prompts/list", request.Params)
+	if err != nil {
+		return nil, err
+	}
+	var result mcp.ListPromptsResult
+	if err = json.Unmarshal(*response, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *StreamableHTTPMCPClient) GetPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	response, err := c.sendRequest(ctx, "prompts/get", request.Params)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.ParseGetPromptResult(response)
+}
+
+func (c *StreamableHTTPMCPClient) ListTools(ctx context.Context, request mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+	response, err := c.sendRequest(ctx, "tools/list", request.Params)
+	if err != nil {
+		return nil, err
+	}
+	var result mcp.ListToolsResult
+	if err = json.Unmarshal(*response, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *StreamableHTTPMCPClient) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	response, err := c.sendRequest(ctx, "tools/call", request.Params)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.ParseCallToolResult(response)
+}
+
+func (c *StreamableHTTPMCPClient) SetLevel(ctx context.Context, request mcp.SetLevelRequest) error {
+	_, err := c.sendRequest(ctx, "logging/setLevel", request.Params)
+	return err
+}
+
+func (c *StreamableHTTPMCPClient) Complete(ctx context.Context, request mcp.CompleteRequest) (*mcp.CompleteResult, error) {
+	response, err := c.sendRequest(ctx, "completion/complete", request.Params)
+	if err != nil {
+		return nil, err
+	}
+	var result mcp.CompleteResult
+	if err = json.Unmarshal(*response, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &result, nil
+}
+
+// OnNotification registers a handler function to be called when notifications are received
+func (c *StreamableHTTPMCPClient) OnNotification(handler func(notification mcp.JSONRPCNotification)) {
+	c.notifyMu.Lock()
+	defer c.notifyMu.Unlock()
+	c.notifications = append(c.notifications, handler)
+}
+
+// Close terminates the session on the server, if the server issued one, giving the server closeTimeout to respond
+func (c *StreamableHTTPMCPClient) Close() error {
+	c.mu.RLock()
+	sessionID := c.sessionID
+	c.mu.RUnlock()
+	if sessionID == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.endpoint.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set(sessionHeader, sessionID)
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to terminate session: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Ensure the Streamable HTTP client satisfies the mcp-go client interface
+var _ client.MCPClient = (*StreamableHTTPMCPClient)(nil)
@@ -0,0 +1,199 @@
+package mcp
+
+import (
+	// Standard
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"sync"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+
+	// 3rd Party
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Toolbox holds the MCP tools the model can use while working on a Mythic task and executes the model's tool calls
+type Toolbox struct {
+	Task   *structs.PTTaskMessageAllData
+	Filter ToolFilter
+	// Session is the task ID of the chat session where the operator approves tool calls, or 0 if approvals are not possible
+	Session int
+	// approval serializes the operator approval prompts of tool calls that run at the same time
+	approval sync.Mutex
+}
+
+// NewToolbox returns the toolbox for the task with the tools selected by the task's tool filter
+func NewToolbox(task *structs.PTTaskMessageAllData) *Toolbox {
+	return &Toolbox{
+		Task:    task,
+		Filter:  NewToolFilter(task),
+		Session: session(task),
+	}
+}
+
+// Tools returns the MCP and built-in tools the model can use
+func (t *Toolbox) Tools() (tools []mcp.Tool) {
+	for _, tool := range t.Filter.GetTools() {
+		if t.Policy(tool.Name) != Deny {
+			tools = append(tools, tool)
+		}
+	}
+	return
+}
+
+// Policy returns how the model is allowed to call the tool, combining the session's tool filter with the built-in provider's guard
+func (t *Toolbox) Policy(name string) Policy {
+	return strictest(t.Filter.Policy(name), providerPolicy(t.Task, name))
+}
+
+// Image is an image returned by an MCP tool
+type Image struct {
+	// MIMEType is the media type of the image (e.g., image/png)
+	MIMEType string
+	// Data is the base64 encoded image
+	Data string
+}
+
+// ToolResult is the outcome of an MCP tool call in a provider neutral format
+type ToolResult struct {
+	// Text is the text content of the result, including text resources and notices about saved files
+	Text string
+	// Images are the images returned by the tool that are passed to the model
+	Images []Image
+	// IsError is true when the MCP server reported that the tool call failed
+	IsError bool
+}
+
+// Execute calls the tool, if its policy allows it, and converts the MCP server's result into a ToolResult.
+// Tools that require confirmation wait for the operator; a denied call is returned to the model as an error result.
+// Text resources are inlined and binary resources are saved to Mythic as files for the task.
+// Text larger than the server's result limit is truncated and the full text is saved to Mythic as a file.
+// When the task records artifacts, every call that runs is registered as a Mythic artifact.
+func (t *Toolbox) Execute(name string, args map[string]interface{}) (result ToolResult, err error) {
+	switch t.Policy(name) {
+	case Deny:
+		return result, fmt.Errorf("tool %s is not enabled for this session", name)
+	case Confirm:
+		if t.Session == 0 {
+			result.IsError = true
+			result.Text = fmt.Sprintf("The tool %s requires operator approval, which is only possible in an interactive chat session", name)
+			return
+		}
+		t.approval.Lock()
+		decision := requestApproval(t.Session, name, args)
+		t.approval.Unlock()
+		if !decision.Approved {
+			result.IsError = true
+			result.Text = "The operator denied the tool call"
+			if decision.Reason != "" {
+				result.Text += ": " + decision.Reason
+			}
+			return
+		}
+	}
+
+	if Records(t.Task, RecordArtifacts) {
+		recordToolCall(t.Task, name, args)
+	}
+
+	// Built-in tools run in the container, everything else is called on its MCP server
+	r, ok, err := callProvider(t.Task, name, args)
+	if !ok {
+		r, err = ExecuteTool(name, args)
+	}
+	if err != nil {
+		return
+	}
+	result.IsError = r.IsError
+
+	var text []string
+	for _, content := range r.Content {
+		switch c := content.(type) {
+		case mcp.TextContent:
+			text = append(text, c.Text)
+		case mcp.ImageContent:
+			result.Images = append(result.Images, Image{MIMEType: c.MIMEType, Data: c.Data})
+		case mcp.EmbeddedResource:
+			text = append(text, ResourceText(t.Task, c.Resource))
+		default:
+			// Tell the model part of the result is missing instead of silently dropping it
+			text = append(text, fmt.Sprintf("⚠️ The tool returned an unsupported content type (%T) that Sage can not process", content))
+		}
+	}
+	// Large results, like a recursive directory listing, would fill the model's context
+	result.Text = truncate(t.Task, name, strings.Join(text, "\n"), resultLimit(name))
+	return
+}
+
+// Call parses the model's JSON arguments and executes the tool with Execute.
+// Invalid arguments, unknown or disabled tools, timeouts, and failed calls are returned to the model as an error result
+// so it can correct the call instead of ending the chat.
+func (t *Toolbox) Call(name string, input []byte) ToolResult {
+	var args map[string]interface{}
+	if len(bytes.TrimSpace(input)) > 0 {
+		if err := json.Unmarshal(input, &args); err != nil {
+			return ToolResult{IsError: true, Text: fmt.Sprintf("The arguments for %s are not a valid JSON object: %s", name, err)}
+		}
+	}
+	result, err := t.Execute(name, args)
+	if err != nil {
+		logging.LogError(err, "the tool call failed", "Tool", name)
+		return ToolResult{IsError: true, Text: fmt.Sprintf("The tool call failed: %s", err)}
+	}
+	return result
+}
+
+// ResourceText returns the resource as text for the model.
+// Text resources are inlined and binary resources are saved to Mythic as files for the task.
+func ResourceText(task *structs.PTTaskMessageAllData, contents mcp.ResourceContents) string {
+	switch resource := contents.(type) {
+	case mcp.TextResourceContents:
+		return fmt.Sprintf("<resource uri=%q mimeType=%q>\n%s\n</resource>", resource.URI, resource.MIMEType, resource.Text)
+	case mcp.BlobResourceContents:
+		return saveBlob(task, resource)
+	default:
+		return fmt.Sprintf("⚠️ The MCP server returned an unsupported resource type (%T) that Sage can not process", resource)
+	}
+}
+
+// saveBlob saves the binary resource to Mythic as a file for the task and returns a notice for the model
+func saveBlob(task *structs.PTTaskMessageAllData, resource mcp.BlobResourceContents) string {
+	data, err := base64.StdEncoding.DecodeString(resource.Blob)
+	if err != nil {
+		return fmt.Sprintf("⚠️ The binary resource %s could not be base64 decoded: %s", resource.URI, err)
+	}
+
+	filename := path.Base(resource.URI)
+	if filename == "." || filename == "/" {
+		filename = "resource"
+	}
+	if path.Ext(filename) == "" {
+		if extensions, _ := mime.ExtensionsByType(resource.MIMEType); len(extensions) > 0 {
+			filename += extensions[0]
+		}
+	}
+
+	msg := mythicrpc.MythicRPCFileCreateMessage{
+		TaskID:       task.Task.ID,
+		FileContents: data,
+		Filename:     filename,
+		Comment:      fmt.Sprintf("MCP resource %s", resource.URI),
+	}
+	resp, err := mythicrpc.SendMythicRPCFileCreate(msg)
+	if err != nil || !resp.Success {
+		if err == nil {
+			err = fmt.Errorf("%s", resp.Error)
+		}
+		logging.LogError(err, "there was an error saving the MCP resource to Mythic", "URI", resource.URI)
+		return fmt.Sprintf("⚠️ The binary resource %s (%s, %d bytes) could not be saved to Mythic: %s", resource.URI, resource.MIMEType, len(data), err)
+	}
+	return fmt.Sprintf("The binary resource %s (%s, %d bytes) was saved to Mythic as the file %s with ID %s", resource.URI, resource.MIMEType, len(data), filename, resp.AgentFileId)
+}
@@ -1,5 +1,11 @@
 package message
 
+import (
+	// Standard
+	"encoding/json"
+	"fmt"
+)
+
 type Role int
 
 const (
@@ -21,7 +27,94 @@ func (r Role) String() string {
 	}
 }
 
+// Kind is what a message holds so the browser scripts can render it
+type Kind string
+
+const (
+	// Text is a prompt or an answer
+	Text Kind = "text"
+	// ToolCall is the model calling a tool
+	ToolCall Kind = "tool_call"
+	// ToolResult is the result of a tool call given back to the model
+	ToolResult Kind = "tool_result"
+	// Thinking is the model's extended thinking
+	Thinking Kind = "thinking"
+	// Error is a failure reported to the operator
+	Error Kind = "error"
+)
+
 type Message struct {
 	Role    Role   `json:"role"`
 	Content string `json:"content"`
+	// Kind is empty for Text messages
+	Kind Kind `json:"kind,omitempty"`
+	// Tool is the name of the tool for ToolCall and ToolResult messages
+	Tool string `json:"tool,omitempty"`
+	// Usage is the tokens the provider reported for the whole exchange; it is only set on the last message of a response
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// Usage is the number of tokens a model consumed to produce a response
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+// Add returns the sum of both token counts
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		InputTokens:  u.InputTokens + other.InputTokens,
+		OutputTokens: u.OutputTokens + other.OutputTokens,
+	}
+}
+
+// Response returns the message as a line of JSON for the task output.
+// The chat and query browser scripts parse these lines to render the conversation.
+func Response(m Message) []byte {
+	kind := m.Kind
+	if kind == "" {
+		kind = Text
+	}
+	b, err := json.Marshal(struct {
+		Role    string `json:"role"`
+		Kind    Kind   `json:"kind"`
+		Tool    string `json:"tool,omitempty"`
+		Content string `json:"content"`
+		Usage   *Usage `json:"usage,omitempty"`
+	}{
+		Role:    m.Role.String(),
+		Kind:    kind,
+		Tool:    m.Tool,
+		Content: m.Content,
+		Usage:   m.Usage,
+	})
+	if err != nil {
+		return []byte(m.Content + "\n")
+	}
+	return append(b, '\n')
+}
+
+// Console returns the message as human-readable text for the interactive chat console, which does not run browser scripts.
+// Each kind of message starts with its own icon, and a response's token usage is on its last line, so the chat browser
+// script can render the same text in the task view.
+func Console(m Message) []byte {
+	var text string
+	switch {
+	case m.Kind == Error:
+		text = fmt.Sprintf("⚠️ %s\n", m.Content)
+	case m.Kind == ToolCall:
+		text = fmt.Sprintf("🛠️ Tool Call: %s\n%s\n", m.Tool, m.Content)
+	case m.Kind == ToolResult:
+		text = fmt.Sprintf("📦 Tool Result: %s\n%s\n", m.Tool, m.Content)
+	case m.Kind == Thinking:
+		text = fmt.Sprintf("🤔 Thinking\n%s\n", m.Content)
+	case m.Role == User:
+		text = fmt.Sprintf("👤> %s\n", m.Content)
+	default:
+		text = fmt.Sprintf("🤖> %s\n", m.Content)
+	}
+	if m.Usage != nil {
+		text += fmt.Sprintf("📊 %d input tokens / %d output tokens\n", m.Usage.InputTokens, m.Usage.OutputTokens)
+	}
+	return []byte(text)
 }
@@ -0,0 +1,59 @@
+package models
+
+import (
+	// Standard
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// TTL is how long a provider's list of models is reused before the provider is asked again
+const TTL = 5 * time.Minute
+
+// Cache holds lists of models by a key for the provider and credentials that listed them.
+// It keeps the Mythic task modal fast when the model parameter's choices are loaded, and is used by providers, like
+// Bedrock, that list their own model type to validate the model string, so every list of models is kept for TTL.
+type Cache[T any] struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry[T]
+}
+
+type cacheEntry[T any] struct {
+	models  []T
+	expires time.Time
+}
+
+// NewCache returns an empty model cache
+func NewCache[T any]() *Cache[T] {
+	return &Cache[T]{entries: make(map[string]cacheEntry[T])}
+}
+
+// Get returns the cached models for the key, or calls list and caches the models it returns for TTL.
+// Errors are not cached so a fixed credential is used the next time the models are requested.
+func (c *Cache[T]) Get(key string, list func() ([]T, error)) ([]T, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.models, nil
+	}
+
+	models, err := list()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[key] = cacheEntry[T]{models: models, expires: time.Now().Add(TTL)}
+	c.mu.Unlock()
+	return models, nil
+}
+
+// Key returns a cache key for the values, like the provider, endpoint, and credentials, without holding the secrets
+func Key(values ...string) string {
+	h := sha256.New()
+	for _, v := range values {
+		h.Write([]byte(v + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,77 @@
+// Package models describes the models a provider offers in the same format for every provider
+package models
+
+import (
+	// Standard
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Model is a model offered by a provider. Fields the provider does not report are left empty.
+type Model struct {
+	// ID is the model string used with the "model" setting
+	ID string `json:"id"`
+	// Name is the human-readable name
+	Name string `json:"name,omitempty"`
+	// ContextWindow is the number of tokens the model accepts
+	ContextWindow int `json:"context_window,omitempty"`
+	// Created is when the model was released or added to the provider
+	Created *time.Time `json:"created,omitempty"`
+	// Capabilities are what the model supports, like its input types, streaming, or tools
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// Filter returns the models with the substring in their ID or name, ignoring case
+func Filter(models []Model, substring string) (filtered []Model) {
+	substring = strings.ToLower(strings.TrimSpace(substring))
+	if substring == "" {
+		return models
+	}
+	for _, m := range models {
+		if strings.Contains(strings.ToLower(m.ID), substring) || strings.Contains(strings.ToLower(m.Name), substring) {
+			filtered = append(filtered, m)
+		}
+	}
+	return
+}
+
+// Table returns the models as a Markdown table
+func Table(models []Model) string {
+	var b strings.Builder
+	b.WriteString("| ID | Name | Context Window | Created | Capabilities |\n|---|---|---|---|---|\n")
+	for _, m := range models {
+		window := ""
+		if m.ContextWindow > 0 {
+			window = fmt.Sprintf("%d", m.ContextWindow)
+		}
+		created := ""
+		if m.Created != nil && !m.Created.IsZero() {
+			created = m.Created.Format(time.DateOnly)
+		}
+		b.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s |\n", m.ID, m.Name, window, created, strings.Join(m.Capabilities, ", ")))
+	}
+	return b.String()
+}
+
+// JSON returns the models as an indented JSON array
+func JSON(models []Model) (string, error) {
+	if models == nil {
+		models = []Model{}
+	}
+	data, err := json.MarshalIndent(models, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Unix returns the time for a Unix timestamp, or nil if the provider did not report one
+func Unix(seconds int64) *time.Time {
+	if seconds <= 0 {
+		return nil
+	}
+	t := time.Unix(seconds, 0).UTC()
+	return &t
+}
@@ -0,0 +1,60 @@
+package mythic
+
+import (
+	// Standard
+	"fmt"
+	"strings"
+)
+
+// stringArg returns the tool argument as a string pointer, or nil if the model did not provide it
+func stringArg(args map[string]interface{}, name string) *string {
+	v, ok := args[name].(string)
+	if !ok || strings.TrimSpace(v) == "" {
+		return nil
+	}
+	return &v
+}
+
+// intArg returns the tool argument as an int pointer, or nil if the model did not provide it.
+// JSON numbers are decoded as float64, but some models send numbers as strings.
+func intArg(args map[string]interface{}, name string) *int {
+	var i int
+	switch v := args[name].(type) {
+	case float64:
+		i = int(v)
+	case int:
+		i = v
+	case string:
+		if _, err := fmt.Sscan(v, &i); err != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return &i
+}
+
+// boolArg returns the tool argument as a bool, or the default value if the model did not provide it
+func boolArg(args map[string]interface{}, name string, value bool) bool {
+	if v, ok := args[name].(bool); ok {
+		return v
+	}
+	return value
+}
+
+// limitArg returns the maximum number of results the model asked for
+func limitArg(args map[string]interface{}) *int {
+	if l := intArg(args, "limit"); l != nil && *l > 0 {
+		return l
+	}
+	l := defaultLimit
+	return &l
+}
+
+// limit truncates the results to the maximum number of results the model asked for
+func limit[T any](results []T, args map[string]interface{}) []T {
+	if l := *limitArg(args); len(results) > l {
+		return results[:l]
+	}
+	return results
+}
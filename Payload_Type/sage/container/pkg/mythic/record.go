@@ -0,0 +1,108 @@
+package mythic
+
+import (
+	// Standard
+	"fmt"
+	"strings"
+
+	// Internal
+	sageMCP "github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/message"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+)
+
+// summaryLength is the maximum number of characters of the model's answer that are kept in the summary
+const summaryLength = 500
+
+// Record writes the model's activity for the task back to Mythic as selected by the task's "record" argument.
+// Artifacts for tool calls are registered by the toolbox as the calls happen; this records the answer itself.
+// Failures are logged and never fail the task.
+func Record(task *structs.PTTaskMessageAllData, provider, model string, output []message.Message) {
+	var usage message.Usage
+	for _, m := range output {
+		if m.Usage != nil {
+			usage = usage.Add(*m.Usage)
+		}
+	}
+	var answer string
+	if len(output) > 0 {
+		answer = output[len(output)-1].Content
+	}
+
+	if sageMCP.Records(task, sageMCP.RecordTags) {
+		tagTask(task, map[string]interface{}{
+			"operator":      task.Task.OperatorUsername,
+			"provider":      provider,
+			"model":         model,
+			"input_tokens":  usage.InputTokens,
+			"output_tokens": usage.OutputTokens,
+			"total_tokens":  usage.InputTokens + usage.OutputTokens,
+		})
+	}
+	if sageMCP.Records(task, sageMCP.RecordComments) && answer != "" {
+		comment(task, fmt.Sprintf("Sage task %d answered with %s:%s: %s", sageMCP.TaskID(task), provider, model, summarize(answer)))
+	}
+}
+
+// tagTask adds a "sage" tag with the data to the task the model's activity is recorded on
+func tagTask(task *structs.PTTaskMessageAllData, data map[string]interface{}) {
+	name := "sage"
+	description := "Model activity recorded by Sage"
+	color := "#7e57c2"
+	tagType, err := mythicrpc.SendMythicRPCTagTypeGetOrCreate(mythicrpc.MythicRPCTagTypeGetOrCreateMessage{
+		TaskID:                        task.Task.ID,
+		GetOrCreateTagTypeName:        &name,
+		GetOrCreateTagTypeDescription: &description,
+		GetOrCreateTagTypeColor:       &color,
+	})
+	if err != nil || !tagType.Success {
+		if err == nil {
+			err = fmt.Errorf("%s", tagType.Error)
+		}
+		logging.LogError(err, "there was an error getting the Sage tag type")
+		return
+	}
+	taskID := sageMCP.TaskID(task)
+	tag, err := mythicrpc.SendMythicRPCTagCreate(mythicrpc.MythicRPCTagCreateMessage{
+		TagTypeID: tagType.TagType.ID,
+		Source:    "sage",
+		TaskID:    &taskID,
+		Data:      data,
+	})
+	if err != nil || !tag.Success {
+		if err == nil {
+			err = fmt.Errorf("%s", tag.Error)
+		}
+		logging.LogError(err, "there was an error tagging the Sage task")
+	}
+}
+
+// comment adds the message to the operation's event log for the task.
+// Mythic RPC can not set a task's comment, so the event log is the closest place operators and reports will see it.
+func comment(task *structs.PTTaskMessageAllData, msg string) {
+	taskID := sageMCP.TaskID(task)
+	resp, err := mythicrpc.SendMythicRPCOperationEventLogCreate(mythicrpc.MythicRPCOperationEventLogCreateMessage{
+		TaskId:       &taskID,
+		Message:      msg,
+		MessageLevel: mythicrpc.MESSAGE_LEVEL_INFO,
+	})
+	if err != nil || !resp.Success {
+		if err == nil {
+			err = fmt.Errorf("%s", resp.Error)
+		}
+		logging.LogError(err, "there was an error adding the Sage summary to the event log")
+	}
+}
+
+// summarize returns the answer on a single line, truncated to summaryLength characters
+func summarize(answer string) string {
+	summary := strings.Join(strings.Fields(answer), " ")
+	if r := []rune(summary); len(r) > summaryLength {
+		summary = string(r[:summaryLength]) + "…"
+	}
+	return summary
+}
@@ -0,0 +1,313 @@
+package mythic
+
+import (
+	// Standard
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	// Internal
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
+	sageMCP "github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/logging"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+
+	// 3rd Party
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// CallbacksArg is the task argument with the callbacks the model is allowed to task
+	CallbacksArg = "agent_callbacks"
+	// CommandsArg is the task argument with the commands the model is allowed to issue to those callbacks
+	CommandsArg = "agent_commands"
+	// ToolsArg is the task argument that gives the model the built-in tools that search the Mythic operation
+	ToolsArg = "mythic_tools"
+	// SecretsArg is the task argument that lets the search_credentials tool return credential values instead of
+	// redacting them. It is an operator setting so the model can never choose to send secrets to the provider.
+	SecretsArg = "mythic_secrets"
+)
+
+const (
+	// defaultTaskTimeout is how long to wait for a spawned task to complete when the model does not provide a timeout
+	defaultTaskTimeout = 2 * time.Minute
+	// maxTaskTimeout is the longest the model can wait for a spawned task to complete
+	maxTaskTimeout = 10 * time.Minute
+	// pollInterval is how often the spawned task is checked for completion
+	pollInterval = 3 * time.Second
+)
+
+// taskCallbackTool returns the tool that lets the model task other callbacks
+func taskCallbackTool() mcp.Tool {
+	return mcp.NewTool("task_callback",
+		mcp.WithDescription("Issue a command to another Mythic callback, wait for it to complete, and return its output. "+
+			"Only the callbacks and commands the operator allowed for this session can be used and the operator must approve every task"),
+		mcp.WithNumber("callback_display_id", mcp.Required(), mcp.Description("The callback number the operator sees in the Mythic UI")),
+		mcp.WithString("command", mcp.Required(), mcp.Description("The name of the command to issue (e.g., ls)")),
+		mcp.WithString("params", mcp.Description("The command's parameters as a command line or a JSON string, as the agent expects them")),
+		mcp.WithNumber("timeout_seconds", mcp.Description(fmt.Sprintf("How long to wait for the task to complete (default %d, max %d)", int(defaultTaskTimeout.Seconds()), int(maxTaskTimeout.Seconds())))),
+	)
+}
+
+// Policy disables the search tools unless the session enables them with the ToolsArg, and disables the
+// task_callback tool unless the session allows at least one callback and command, requiring the operator's approval
+// for every call to it
+func (Tools) Policy(task *structs.PTTaskMessageAllData, name string) sageMCP.Policy {
+	if name != "task_callback" {
+		if enabled, _ := task.Args.GetBooleanArg(ToolsArg); !enabled {
+			return sageMCP.Deny
+		}
+		return sageMCP.Auto
+	}
+	if !NewAllowlist(task).Enabled() {
+		return sageMCP.Deny
+	}
+	return sageMCP.Confirm
+}
+
+// Allowlist is the callbacks and commands the model is allowed to task in a session
+type Allowlist struct {
+	// Callbacks are the display IDs of the callbacks the model can task
+	Callbacks []int
+	// Commands are the command names, or glob patterns, the model can issue
+	Commands []string
+}
+
+// NewAllowlist returns the allowlist from the task's "agent_callbacks" and "agent_commands" arguments
+func NewAllowlist(task *structs.PTTaskMessageAllData) (allowlist Allowlist) {
+	callbacks, _ := task.Args.GetChooseMultipleArg(CallbacksArg)
+	for _, c := range callbacks {
+		if id, err := ParseCallbackChoice(c); err == nil {
+			allowlist.Callbacks = append(allowlist.Callbacks, id)
+		}
+	}
+	commands, _ := task.Args.GetArrayArg(CommandsArg)
+	for _, c := range commands {
+		if c = strings.TrimSpace(c); c != "" {
+			allowlist.Commands = append(allowlist.Commands, c)
+		}
+	}
+	return
+}
+
+// Enabled returns true if the model is allowed to task at least one callback with at least one command
+func (a Allowlist) Enabled() bool {
+	return len(a.Callbacks) > 0 && len(a.Commands) > 0
+}
+
+// Allowed returns an error if the callback or command is not in the allowlist
+func (a Allowlist) Allowed(callback int, command string) error {
+	found := false
+	for _, c := range a.Callbacks {
+		if c == callback {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("callback %d is not in the list of callbacks the operator allowed, the allowed callbacks are %v", callback, a.Callbacks)
+	}
+	for _, pattern := range a.Commands {
+		if ok, err := path.Match(pattern, command); pattern == command || (err == nil && ok) {
+			return nil
+		}
+	}
+	return fmt.Errorf("command %s is not in the list of commands the operator allowed, the allowed commands are %v", command, a.Commands)
+}
+
+// CallbackChoices returns the active callbacks, other than the Sage callback, in the "<display id> - <user>@<host> (<process>)" format.
+// These are the choices operators pick from to build an allowlist.
+func CallbackChoices(callback int) (choices []string) {
+	resp, err := mythicrpc.SendMythicRPCCallbackSearch(mythicrpc.MythicRPCCallbackSearchMessage{AgentCallbackID: callback})
+	if err != nil || !resp.Success {
+		if err == nil {
+			err = fmt.Errorf("%s", resp.Error)
+		}
+		logging.LogError(err, "there was an error searching for callbacks")
+		return
+	}
+	for _, c := range resp.Results {
+		if !c.Active || c.ID == callback {
+			continue
+		}
+		choices = append(choices, fmt.Sprintf("%d - %s@%s (%s)", c.DisplayID, c.User, c.Host, c.ProcessName))
+	}
+	return
+}
+
+// ParseCallbackChoice returns the callback display ID from a choice returned by CallbackChoices or a plain display ID
+func ParseCallbackChoice(choice string) (id int, err error) {
+	if _, err = fmt.Sscanf(strings.TrimSpace(choice), "%d", &id); err != nil {
+		err = fmt.Errorf("'%s' does not start with a callback display ID: %w", choice, err)
+	}
+	return
+}
+
+// SpawnedTask is a task the model issued to another callback and the output it returned
+type SpawnedTask struct {
+	TaskOutput
+	DisplayID      int    `json:"display_id"`
+	CallbackHost   string `json:"callback_host"`
+	TimedOut       bool   `json:"timed_out,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+// taskCallback issues the command to the callback after checking the allowlist, records the audit trail, and waits for the output.
+// The operator's approval is requested by the toolbox before this is called.
+func taskCallback(task *structs.PTTaskMessageAllData, args map[string]interface{}) (spawned SpawnedTask, err error) {
+	allowlist := NewAllowlist(task)
+	displayID := intArg(args, "callback_display_id")
+	command := stringArg(args, "command")
+	if displayID == nil || command == nil {
+		return spawned, fmt.Errorf("callback_display_id and command are required")
+	}
+	if err = allowlist.Allowed(*displayID, *command); err != nil {
+		return
+	}
+	params := ""
+	if p := stringArg(args, "params"); p != nil {
+		params = *p
+	}
+	timeout := defaultTaskTimeout
+	if t := intArg(args, "timeout_seconds"); t != nil && *t > 0 {
+		timeout = min(time.Duration(*t)*time.Second, maxTaskTimeout)
+	}
+	spawned.TimeoutSeconds = int(timeout.Seconds())
+
+	callbacks, err := mythicrpc.SendMythicRPCCallbackSearch(mythicrpc.MythicRPCCallbackSearchMessage{
+		AgentCallbackID:         task.Callback.ID,
+		SearchCallbackDisplayID: displayID,
+	})
+	if err != nil {
+		err = fmt.Errorf("the Mythic callback search failed: %w", err)
+		return
+	}
+	if !callbacks.Success {
+		err = fmt.Errorf("the Mythic callback search failed: %s", callbacks.Error)
+		return
+	}
+	if len(callbacks.Results) == 0 {
+		return spawned, fmt.Errorf("callback %d was not found", *displayID)
+	}
+	target := callbacks.Results[0]
+	if !target.Active {
+		return spawned, fmt.Errorf("callback %d is not active", *displayID)
+	}
+	spawned.CallbackHost = target.Host
+
+	created, err := mythicrpc.SendMythicRPCTaskCreate(mythicrpc.MythicRPCTaskCreateMessage{
+		AgentCallbackID: target.AgentCallbackID,
+		CommandName:     *command,
+		Params:          params,
+	})
+	if err != nil {
+		err = fmt.Errorf("the Mythic task create failed: %w", err)
+		return
+	}
+	if !created.Success {
+		err = fmt.Errorf("the Mythic task create failed: %s", created.Error)
+		return
+	}
+	spawned.DisplayID = created.TaskDisplayID
+	audit(task, target, *command, params, created)
+
+	// Wait for the agent to pick up the task and return its output
+	var t *mythicrpc.PTTaskMessageTaskData
+	deadline := time.Now().Add(timeout)
+	for {
+		tasks, e := mythicrpc.SendMythicRPCTaskSearch(mythicrpc.MythicRPCTaskSearchMessage{
+			TaskID:       task.Task.ID,
+			SearchTaskID: &created.TaskID,
+		})
+		if e == nil && tasks.Success && len(tasks.Tasks) > 0 {
+			t = &tasks.Tasks[0]
+			if t.Completed || strings.Contains(strings.ToLower(t.Status), "error") {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			spawned.TimedOut = true
+			break
+		}
+		time.Sleep(pollInterval)
+	}
+	if t != nil {
+		spawned.Task = newTask(*t)
+	}
+	spawned.ID = created.TaskID
+
+	responses, err := mythicrpc.SendMythicRPCResponseSearch(mythicrpc.MythicRPCResponseSearchMessage{TaskID: created.TaskID})
+	if err != nil {
+		err = fmt.Errorf("the Mythic response search failed: %w", err)
+		return
+	}
+	if !responses.Success {
+		err = fmt.Errorf("the Mythic response search failed: %s", responses.Error)
+		return
+	}
+	var b strings.Builder
+	for _, r := range responses.Responses {
+		b.Write(r.Response)
+	}
+	spawned.Output = b.String()
+	return
+}
+
+// audit links the Sage task to the task it spawned with a tag on the spawned task and a message in the Sage task's output
+func audit(task *structs.PTTaskMessageAllData, target mythicrpc.MythicRPCCallbackSearchMessageResult, command, params string, created *mythicrpc.MythicRPCTaskCreateMessageResponse) {
+	// Interactive chat messages are subtasks, the audit trail belongs to the chat session
+	sageTask := sageMCP.TaskID(task)
+	provider, _ := env.Get(task, "provider")
+	model, _ := env.Get(task, "model")
+
+	logging.LogInfo("Sage tasked a callback", "sage_task", sageTask, "callback", target.DisplayID, "task", created.TaskDisplayID, "command", command, "params", params, "operator", task.Task.OperatorUsername)
+
+	_, err := mythicrpc.SendMythicRPCResponseCreate(mythicrpc.MythicRPCResponseCreateMessage{
+		TaskID:   sageTask,
+		Response: []byte(fmt.Sprintf("🛰️ Tasked callback %d (%s@%s) with %s %s as task %d\n", target.DisplayID, target.User, target.Host, command, params, created.TaskDisplayID)),
+	})
+	if err != nil {
+		logging.LogError(err, "there was an error adding the spawned task to the Sage task output")
+	}
+
+	name := "sage"
+	description := "Task issued by a model through Sage"
+	color := "#7e57c2"
+	tagType, err := mythicrpc.SendMythicRPCTagTypeGetOrCreate(mythicrpc.MythicRPCTagTypeGetOrCreateMessage{
+		TaskID:                        task.Task.ID,
+		GetOrCreateTagTypeName:        &name,
+		GetOrCreateTagTypeDescription: &description,
+		GetOrCreateTagTypeColor:       &color,
+	})
+	if err != nil || !tagType.Success {
+		if err == nil {
+			err = fmt.Errorf("%s", tagType.Error)
+		}
+		logging.LogError(err, "there was an error getting the Sage tag type")
+		return
+	}
+	tag, err := mythicrpc.SendMythicRPCTagCreate(mythicrpc.MythicRPCTagCreateMessage{
+		TagTypeID: tagType.TagType.ID,
+		Source:    "sage",
+		TaskID:    &created.TaskID,
+		Data: map[string]interface{}{
+			"sage_task_id":  sageTask,
+			"sage_callback": task.Callback.DisplayID,
+			"operator":      task.Task.OperatorUsername,
+			"provider":      provider,
+			"model":         model,
+			"command":       command,
+			"params":        params,
+		},
+	})
+	if err != nil || !tag.Success {
+		if err == nil {
+			err = fmt.Errorf("%s", tag.Error)
+		}
+		logging.LogError(err, "there was an error tagging the spawned task")
+	}
+}
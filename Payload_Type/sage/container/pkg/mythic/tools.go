@@ -0,0 +1,468 @@
+package mythic
+
+import (
+	// Standard
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+	"github.com/MythicMeta/MythicContainer/mythicrpc"
+
+	// 3rd Party
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Alias is the prefix for the names of the built-in Mythic tools (e.g., mythic__search_callbacks)
+const Alias = "mythic"
+
+// defaultLimit is the maximum number of results returned to the model when it does not provide a limit
+const defaultLimit = 50
+
+// Tools is a built-in tool provider that lets the model search the Mythic operation through MythicRPC.
+// Every search is scoped to the operation of the Sage callback the task belongs to.
+type Tools struct{}
+
+// Alias returns the prefix for the names of the Mythic tools
+func (Tools) Alias() string {
+	return Alias
+}
+
+// Tools returns the Mythic tools
+func (Tools) Tools() []mcp.Tool {
+	limit := mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("The maximum number of results to return (default %d)", defaultLimit)))
+	return []mcp.Tool{
+		mcp.NewTool("search_callbacks",
+			mcp.WithDescription("Search the callbacks in the current Mythic operation. Integrity levels are 1 (low), 2 (medium), 3 (high), and 4 (SYSTEM)"),
+			mcp.WithString("host", mcp.Description("The hostname of the callback")),
+			mcp.WithString("user", mcp.Description("The username the callback is running as")),
+			mcp.WithString("domain", mcp.Description("The domain of the callback")),
+			mcp.WithString("ip", mcp.Description("The IP address of the callback")),
+			mcp.WithString("os", mcp.Description("The detailed operating system information of the callback")),
+			mcp.WithString("description", mcp.Description("The description of the callback")),
+			mcp.WithString("payload_type", mcp.Description("The payload type the callback is based on (e.g., apollo, poseidon)")),
+			mcp.WithNumber("integrity_level", mcp.Description("The integrity level of the callback")),
+			mcp.WithBoolean("active_only", mcp.Description("Only return active callbacks (default true)")),
+			limit,
+		),
+		mcp.NewTool("search_tasks",
+			mcp.WithDescription("Search the tasks in the current Mythic operation. Results contain the task's internal ID, which can be used with get_task_output"),
+			mcp.WithNumber("callback_display_id", mcp.Description("The callback number the operator sees in the Mythic UI")),
+			mcp.WithString("host", mcp.Description("The hostname the task ran on")),
+			mcp.WithString("command", mcp.Description("The name of the command (e.g., ls, ps, whoami)")),
+			mcp.WithString("params", mcp.Description("Text the task's parameters contain")),
+			mcp.WithBoolean("completed", mcp.Description("Only return completed, or not completed, tasks")),
+			limit,
+		),
+		mcp.NewTool("get_task_output",
+			mcp.WithDescription("Get a Mythic task and the output it returned. Provide the task number the operator sees in the Mythic UI, or the internal task ID from search_tasks"),
+			mcp.WithNumber("task_display_id", mcp.Description("The task number the operator sees in the Mythic UI")),
+			mcp.WithNumber("task_id", mcp.Description("The internal task ID returned by search_tasks")),
+		),
+		mcp.NewTool("search_files",
+			mcp.WithDescription("Search the files tracked by Mythic, including files downloaded from agents, screenshots, and uploaded files"),
+			mcp.WithString("filename", mcp.Description("Text the filename contains")),
+			mcp.WithString("comment", mcp.Description("Text the file's comment contains")),
+			mcp.WithBoolean("downloads", mcp.Description("Only return files downloaded from agents")),
+			mcp.WithBoolean("screenshots", mcp.Description("Only return screenshots")),
+			mcp.WithBoolean("payloads", mcp.Description("Only return payloads")),
+			limit,
+		),
+		mcp.NewTool("search_credentials",
+			mcp.WithDescription("Search the credentials stored in Mythic. Credential values are redacted unless the operator allowed them for this session"),
+			mcp.WithString("type", mcp.Description("The type of credential (e.g., plaintext, hash, key, ticket, cookie)")),
+			mcp.WithString("account", mcp.Description("The account the credential belongs to")),
+			mcp.WithString("realm", mcp.Description("The realm or domain of the credential")),
+			mcp.WithString("comment", mcp.Description("Text the credential's comment contains")),
+			limit,
+		),
+		mcp.NewTool("search_processes",
+			mcp.WithDescription("Search the process listings agents have reported to Mythic. Integrity levels are 1 (low), 2 (medium), 3 (high), and 4 (SYSTEM)"),
+			mcp.WithString("host", mcp.Description("The hostname the process is running on")),
+			mcp.WithString("name", mcp.Description("The process name")),
+			mcp.WithString("user", mcp.Description("The user the process is running as")),
+			mcp.WithString("command_line", mcp.Description("Text the process command line contains")),
+			mcp.WithNumber("pid", mcp.Description("The process ID")),
+			mcp.WithNumber("parent_pid", mcp.Description("The parent process ID")),
+			mcp.WithNumber("integrity_level", mcp.Description("The integrity level of the process")),
+			limit,
+		),
+		mcp.NewTool("search_tags",
+			mcp.WithDescription("Search the tags operators and agents have added to Mythic data"),
+			mcp.WithString("source", mcp.Description("The source of the tag")),
+			mcp.WithString("data", mcp.Description("Text the tag's data contains")),
+			mcp.WithString("url", mcp.Description("The URL of the tag")),
+			limit,
+		),
+		mcp.NewTool("search_artifacts",
+			mcp.WithDescription("Search the artifacts (indicators such as process creation, file writes, or network connections) tasks created"),
+			mcp.WithString("host", mcp.Description("The hostname the artifact was created on")),
+			mcp.WithString("type", mcp.Description("The type of artifact (e.g., Process Create, File Write)")),
+			mcp.WithString("message", mcp.Description("Text the artifact's message contains")),
+			mcp.WithNumber("task_id", mcp.Description("The internal ID of the task that created the artifact")),
+			limit,
+		),
+		taskCallbackTool(),
+	}
+}
+
+// CallTool runs the Mythic tool with the provided name. Failed searches are returned to the model as error results.
+func (Tools) CallTool(task *structs.PTTaskMessageAllData, name string, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	var result interface{}
+	var err error
+	switch name {
+	case "search_callbacks":
+		result, err = searchCallbacks(task, args)
+	case "search_tasks":
+		result, err = searchTasks(task, args)
+	case "get_task_output":
+		result, err = getTaskOutput(task, args)
+	case "search_files":
+		result, err = searchFiles(task, args)
+	case "search_credentials":
+		result, err = searchCredentials(task, args)
+	case "search_processes":
+		result, err = searchProcesses(task, args)
+	case "search_tags":
+		result, err = searchTags(task, args)
+	case "search_artifacts":
+		result, err = searchArtifacts(task, args)
+	case "task_callback":
+		result, err = taskCallback(task, args)
+	default:
+		return nil, fmt.Errorf("unknown Mythic tool %s", name)
+	}
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("there was an error marshalling the %s result: %w", name, err)
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+// Callback is the information about a callback that is returned to the model
+type Callback struct {
+	ID             int       `json:"id"`
+	DisplayID      int       `json:"display_id"`
+	Host           string    `json:"host"`
+	User           string    `json:"user"`
+	Domain         string    `json:"domain"`
+	PID            int       `json:"pid"`
+	ProcessName    string    `json:"process_name"`
+	IP             string    `json:"ip"`
+	ExternalIP     string    `json:"external_ip"`
+	OS             string    `json:"os"`
+	Architecture   string    `json:"architecture"`
+	IntegrityLevel int       `json:"integrity_level"`
+	Active         bool      `json:"active"`
+	LastCheckin    time.Time `json:"last_checkin"`
+	Description    string    `json:"description"`
+}
+
+func searchCallbacks(task *structs.PTTaskMessageAllData, args map[string]interface{}) (callbacks []Callback, err error) {
+	msg := mythicrpc.MythicRPCCallbackSearchMessage{
+		AgentCallbackID:              task.Callback.ID,
+		SearchCallbackHost:           stringArg(args, "host"),
+		SearchCallbackUser:           stringArg(args, "user"),
+		SearchCallbackDomain:         stringArg(args, "domain"),
+		SearchCallbackIP:             stringArg(args, "ip"),
+		SearchCallbackOs:             stringArg(args, "os"),
+		SearchCallbackDescription:    stringArg(args, "description"),
+		SearchCallbackIntegrityLevel: intArg(args, "integrity_level"),
+	}
+	if payloadType := stringArg(args, "payload_type"); payloadType != nil {
+		msg.SearchCallbackPayloadTypes = &[]string{*payloadType}
+	}
+	resp, err := mythicrpc.SendMythicRPCCallbackSearch(msg)
+	if err != nil {
+		err = fmt.Errorf("the Mythic callback search failed: %w", err)
+		return
+	}
+	if !resp.Success {
+		err = fmt.Errorf("the Mythic callback search failed: %s", resp.Error)
+		return
+	}
+
+	activeOnly := boolArg(args, "active_only", true)
+	for _, c := range resp.Results {
+		if activeOnly && !c.Active {
+			continue
+		}
+		// The callback's encryption keys are never given to the model
+		callbacks = append(callbacks, Callback{
+			ID:             c.ID,
+			DisplayID:      c.DisplayID,
+			Host:           c.Host,
+			User:           c.User,
+			Domain:         c.Domain,
+			PID:            c.PID,
+			ProcessName:    c.ProcessName,
+			IP:             c.Ip,
+			ExternalIP:     c.ExternalIp,
+			OS:             c.Os,
+			Architecture:   c.Architecture,
+			IntegrityLevel: c.IntegrityLevel,
+			Active:         c.Active,
+			LastCheckin:    c.LastCheckin,
+			Description:    c.Description,
+		})
+	}
+	return limit(callbacks, args), nil
+}
+
+// Task is the information about a task that is returned to the model
+type Task struct {
+	ID            int    `json:"id"`
+	CallbackID    int    `json:"callback_id"`
+	Command       string `json:"command"`
+	DisplayParams string `json:"display_params"`
+	Status        string `json:"status"`
+	Completed     bool   `json:"completed"`
+	Operator      string `json:"operator"`
+	Timestamp     string `json:"timestamp"`
+	Comment       string `json:"comment,omitempty"`
+}
+
+func newTask(t mythicrpc.PTTaskMessageTaskData) Task {
+	return Task{
+		ID:            t.ID,
+		CallbackID:    t.CallbackID,
+		Command:       t.CommandName,
+		DisplayParams: t.DisplayParams,
+		Status:        t.Status,
+		Completed:     t.Completed,
+		Operator:      t.OperatorUsername,
+		Timestamp:     t.Timestamp,
+		Comment:       t.Comment,
+	}
+}
+
+func searchTasks(task *structs.PTTaskMessageAllData, args map[string]interface{}) (tasks []Task, err error) {
+	msg := mythicrpc.MythicRPCTaskSearchMessage{
+		TaskID:       task.Task.ID,
+		SearchHost:   stringArg(args, "host"),
+		SearchParams: stringArg(args, "params"),
+	}
+	if completed, ok := args["completed"].(bool); ok {
+		msg.SearchCompleted = &completed
+	}
+	if command := stringArg(args, "command"); command != nil {
+		msg.SearchCommandNames = &[]string{*command}
+	}
+	if displayID := intArg(args, "callback_display_id"); displayID != nil {
+		var lookup *mythicrpc.MythicRPCCallbackDisplayToRealIdSearchMessageResponse
+		lookup, err = mythicrpc.SendMythicRPCCallbackDisplayToRealIdSearch(mythicrpc.MythicRPCCallbackDisplayToRealIdSearchMessage{
+			CallbackDisplayID: *displayID,
+			OperationID:       &task.Callback.OperationID,
+		})
+		if err != nil {
+			err = fmt.Errorf("the Mythic callback lookup failed: %w", err)
+			return
+		}
+		if !lookup.Success {
+			err = fmt.Errorf("the Mythic callback lookup failed: %s", lookup.Error)
+			return
+		}
+		msg.SearchCallbackID = &lookup.CallbackID
+	}
+
+	resp, err := mythicrpc.SendMythicRPCTaskSearch(msg)
+	if err != nil {
+		err = fmt.Errorf("the Mythic task search failed: %w", err)
+		return
+	}
+	if !resp.Success {
+		err = fmt.Errorf("the Mythic task search failed: %s", resp.Error)
+		return
+	}
+	for _, t := range resp.Tasks {
+		tasks = append(tasks, newTask(t))
+	}
+	return limit(tasks, args), nil
+}
+
+// TaskOutput is a task and the output it returned
+type TaskOutput struct {
+	Task
+	Output string `json:"output"`
+}
+
+func getTaskOutput(task *structs.PTTaskMessageAllData, args map[string]interface{}) (output TaskOutput, err error) {
+	id := intArg(args, "task_id")
+	if displayID := intArg(args, "task_display_id"); displayID != nil {
+		var lookup *mythicrpc.MythicRPCTaskDisplayToRealIdSearchMessageResponse
+		lookup, err = mythicrpc.SendMythicRPCTaskDisplayToRealIdSearch(mythicrpc.MythicRPCTaskDisplayToRealIdSearchMessage{
+			TaskDisplayID: *displayID,
+			OperationID:   &task.Callback.OperationID,
+		})
+		if err != nil {
+			err = fmt.Errorf("the Mythic task lookup failed: %w", err)
+			return
+		}
+		if !lookup.Success {
+			err = fmt.Errorf("the Mythic task lookup failed: %s", lookup.Error)
+			return
+		}
+		id = &lookup.TaskID
+	}
+	if id == nil {
+		return output, fmt.Errorf("either task_display_id or task_id is required")
+	}
+
+	tasks, err := mythicrpc.SendMythicRPCTaskSearch(mythicrpc.MythicRPCTaskSearchMessage{
+		TaskID:       task.Task.ID,
+		SearchTaskID: id,
+	})
+	if err != nil {
+		err = fmt.Errorf("the Mythic task search failed: %w", err)
+		return
+	}
+	if !tasks.Success {
+		err = fmt.Errorf("the Mythic task search failed: %s", tasks.Error)
+		return
+	}
+	if len(tasks.Tasks) == 0 {
+		return output, fmt.Errorf("task %d was not found", *id)
+	}
+	output.Task = newTask(tasks.Tasks[0])
+
+	responses, err := mythicrpc.SendMythicRPCResponseSearch(mythicrpc.MythicRPCResponseSearchMessage{TaskID: *id})
+	if err != nil {
+		err = fmt.Errorf("the Mythic response search failed: %w", err)
+		return
+	}
+	if !responses.Success {
+		err = fmt.Errorf("the Mythic response search failed: %s", responses.Error)
+		return
+	}
+	var b strings.Builder
+	for _, r := range responses.Responses {
+		b.Write(r.Response)
+	}
+	output.Output = b.String()
+	return
+}
+
+func searchFiles(task *structs.PTTaskMessageAllData, args map[string]interface{}) (files []mythicrpc.FileData, err error) {
+	msg := mythicrpc.MythicRPCFileSearchMessage{
+		TaskID:              task.Task.ID,
+		CallbackID:          task.Callback.ID,
+		LimitByCallback:     false,
+		MaxResults:          *limitArg(args),
+		IsDownloadFromAgent: boolArg(args, "downloads", false),
+		IsScreenshot:        boolArg(args, "screenshots", false),
+		IsPayload:           boolArg(args, "payloads", false),
+	}
+	if filename := stringArg(args, "filename"); filename != nil {
+		msg.Filename = *filename
+	}
+	if comment := stringArg(args, "comment"); comment != nil {
+		msg.Comment = *comment
+	}
+	resp, err := mythicrpc.SendMythicRPCFileSearch(msg)
+	if err != nil {
+		err = fmt.Errorf("the Mythic file search failed: %w", err)
+		return
+	}
+	if !resp.Success {
+		err = fmt.Errorf("the Mythic file search failed: %s", resp.Error)
+		return
+	}
+	return limit(resp.Files, args), nil
+}
+
+func searchCredentials(task *structs.PTTaskMessageAllData, args map[string]interface{}) (credentials []mythicrpc.MythicRPCCredentialSearchCredentialData, err error) {
+	resp, err := mythicrpc.SendMythicRPCCredentialSearch(mythicrpc.MythicRPCCredentialSearchMessage{
+		TaskID: task.Task.ID,
+		SearchCredentials: mythicrpc.MythicRPCCredentialSearchCredentialData{
+			Type:    stringArg(args, "type"),
+			Account: stringArg(args, "account"),
+			Realm:   stringArg(args, "realm"),
+			Comment: stringArg(args, "comment"),
+		},
+	})
+	if err != nil {
+		err = fmt.Errorf("the Mythic credential search failed: %w", err)
+		return
+	}
+	if !resp.Success {
+		err = fmt.Errorf("the Mythic credential search failed: %s", resp.Error)
+		return
+	}
+	credentials = limit(resp.Credentials, args)
+	// Only the operator can allow the credential values to be sent to the model provider
+	if secrets, _ := task.Args.GetBooleanArg(SecretsArg); !secrets {
+		redacted := "<redacted>"
+		for i := range credentials {
+			credentials[i].Credential = &redacted
+		}
+	}
+	return
+}
+
+func searchProcesses(task *structs.PTTaskMessageAllData, args map[string]interface{}) (processes []mythicrpc.MythicRPCProcessSearchProcessData, err error) {
+	resp, err := mythicrpc.SendMythicRPCProcessSearch(mythicrpc.MythicRPCProcessSearchMessage{
+		TaskID: task.Task.ID,
+		SearchProcess: mythicrpc.MythicRPCProcessSearchProcessData{
+			Host:            stringArg(args, "host"),
+			Name:            stringArg(args, "name"),
+			User:            stringArg(args, "user"),
+			CommandLine:     stringArg(args, "command_line"),
+			ProcessID:       intArg(args, "pid"),
+			ParentProcessID: intArg(args, "parent_pid"),
+			IntegrityLevel:  intArg(args, "integrity_level"),
+		},
+	})
+	if err != nil {
+		err = fmt.Errorf("the Mythic process search failed: %w", err)
+		return
+	}
+	if !resp.Success {
+		err = fmt.Errorf("the Mythic process search failed: %s", resp.Error)
+		return
+	}
+	return limit(resp.Processes, args), nil
+}
+
+func searchTags(task *structs.PTTaskMessageAllData, args map[string]interface{}) (tags []mythicrpc.MythicRPCTagData, err error) {
+	resp, err := mythicrpc.SendMythicRPCTagSearch(mythicrpc.MythicRPCTagSearchMessage{
+		TaskID:          task.Task.ID,
+		SearchTagSource: stringArg(args, "source"),
+		SearchTagData:   stringArg(args, "data"),
+		SearchTagURL:    stringArg(args, "url"),
+	})
+	if err != nil {
+		err = fmt.Errorf("the Mythic tag search failed: %w", err)
+		return
+	}
+	if !resp.Success {
+		err = fmt.Errorf("the Mythic tag search failed: %s", resp.Error)
+		return
+	}
+	return limit(resp.Tags, args), nil
+}
+
+func searchArtifacts(task *structs.PTTaskMessageAllData, args map[string]interface{}) (artifacts []mythicrpc.MythicRPCArtifactearchArtifactData, err error) {
+	resp, err := mythicrpc.SendMythicRPCArtifactSearch(mythicrpc.MythicRPCArtifactSearchMessage{
+		TaskID: task.Task.ID,
+		SearchArtifacts: mythicrpc.MythicRPCArtifactearchArtifactData{
+			Host:            stringArg(args, "host"),
+			ArtifactType:    stringArg(args, "type"),
+			ArtifactMessage: stringArg(args, "message"),
+			TaskID:          intArg(args, "task_id"),
+		},
+	})
+	if err != nil {
+		err = fmt.Errorf("the Mythic artifact search failed: %w", err)
+		return
+	}
+	if !resp.Success {
+		err = fmt.Errorf("the Mythic artifact search failed: %s", resp.Error)
+		return
+	}
+	return limit(resp.Artifacts, args), nil
+}
@@ -10,32 +10,38 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
+	"sync"
+	"time"
 
 	// Internal
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/message"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/models"
 
 	// Mythic
 	structs "github.com/MythicMeta/MythicContainer/agent_structs"
 	"github.com/MythicMeta/MythicContainer/logging"
 )
 
-func List(task *structs.PTTaskMessageAllData) (output string, err error) {
+// List returns the models that are pulled on the Ollama server
+func List(task *structs.PTTaskMessageAllData) (list []models.Model, err error) {
 	// Get the OLLAMA_API_ENDPOINT
 	OLLAMA_API_ENDPOINT, err := env.Get(task, "API_ENDPOINT")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	endpoint := "api/tags"
 
 	parsedBase, err := url.Parse(OLLAMA_API_ENDPOINT)
 	if err != nil {
-		return "", fmt.Errorf("invalid base URL: %w", err)
+		return nil, fmt.Errorf("invalid base URL: %w", err)
 	}
 
 	parsedEndpoint, err := url.Parse(endpoint)
 	if err != nil {
-		return "", fmt.Errorf("invalid endpoint: %w", err)
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
 	}
 
 	// Ensure the endpoint is properly joined with the base URL
@@ -44,7 +50,7 @@ func List(task *structs.PTTaskMessageAllData) (output string, err error) {
 	// Create the GET request
 	req, err := http.NewRequest("GET", parsedBase.String(), nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GET request: %s", err)
+		return nil, fmt.Errorf("failed to create GET request: %s", err)
 	}
 
 	// Create the HTTP client
@@ -58,26 +64,31 @@ func List(task *structs.PTTaskMessageAllData) (output string, err error) {
 	logging.LogDebug(fmt.Sprintf("Sending GET request to %s", parsedBase.String()))
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send GET request: %s", err)
+		return nil, fmt.Errorf("failed to send GET request: %s", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	// Unmarshal JSON response into struct
 	var response ModelList
 	err = json.Unmarshal(body, &response)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal JSON: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
 	}
 	logging.LogDebug(fmt.Sprintf("Data: %+v", response))
 
 	for _, m := range response.Models {
-		output += fmt.Sprintf("%s\n", m.Name)
+		modified := m.ModifiedAt
+		list = append(list, models.Model{
+			ID:      m.Name,
+			Name:    strings.TrimSpace(strings.Join([]string{m.Details.Family, m.Details.ParameterSize, m.Details.QuantizationLevel}, " ")),
+			Created: &modified,
+		})
 	}
 	return
 }
@@ -155,3 +166,126 @@ func Generate(task *structs.PTTaskMessageAllData, modelID string, prompt string)
 
 	return
 }
+
+// servers caches whether an API endpoint is an Ollama server so the endpoint is only probed once
+var servers = struct {
+	sync.Mutex
+	endpoints map[string]bool
+}{endpoints: make(map[string]bool)}
+
+// nativeURL returns the URL of the Ollama native API path for an OpenAI compatible endpoint like http://127.0.0.1:11434/v1
+func nativeURL(endpoint, api string) (string, error) {
+	parsedBase, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	parsedBase.Path = path.Join(strings.TrimSuffix(strings.TrimSuffix(parsedBase.Path, "/"), "/v1"), api)
+	return parsedBase.String(), nil
+}
+
+// IsServer returns true if the OpenAI compatible API endpoint is served by Ollama, which is determined by asking the
+// server for its Ollama version. The answer is cached for the endpoint.
+func IsServer(endpoint string) bool {
+	servers.Lock()
+	defer servers.Unlock()
+	if ok, found := servers.endpoints[endpoint]; found {
+		return ok
+	}
+
+	ok := false
+	if u, err := nativeURL(endpoint, "api/version"); err == nil {
+		client := &http.Client{
+			Timeout: 5 * time.Second,
+			// allow insecure tls
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+		if resp, err := client.Get(u); err == nil {
+			var version struct {
+				Version string `json:"version"`
+			}
+			ok = resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(&version) == nil && version.Version != ""
+			resp.Body.Close()
+		}
+	}
+	servers.endpoints[endpoint] = ok
+	logging.LogDebug("Checked if the API endpoint is an Ollama server", "Endpoint", endpoint, "Ollama", ok)
+	return ok
+}
+
+// ChatFormat sends the messages to the model with Ollama's native /api/chat endpoint and its format parameter, which
+// constrains the model's answer to the JSON schema. Ollama's OpenAI compatible endpoint is not used because it does not
+// take the format parameter.
+func ChatFormat(task *structs.PTTaskMessageAllData, modelID string, msgs []message.Message, format json.RawMessage) (answer string, usage message.Usage, err error) {
+	// Get the OLLAMA_API_ENDPOINT
+	OLLAMA_API_ENDPOINT, err := env.Get(task, "API_ENDPOINT")
+	if err != nil {
+		return
+	}
+
+	request := ChatRequest{
+		Model:  modelID,
+		Stream: false,
+		Format: format,
+	}
+	for _, m := range msgs {
+		request.Messages = append(request.Messages, ChatMessage{Role: m.Role.String(), Content: m.Content})
+	}
+
+	// Marshal request into JSON
+	reqBody, err := json.Marshal(request)
+	if err != nil {
+		return "", usage, fmt.Errorf("failed to marshal request into JSON: %s", err)
+	}
+
+	u, err := nativeURL(OLLAMA_API_ENDPOINT, "api/chat")
+	if err != nil {
+		return
+	}
+
+	// Create the POST request
+	req, err := http.NewRequest("POST", u, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return "", usage, fmt.Errorf("failed to create POST request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// An API key is only needed when Ollama is behind a proxy that requires one
+	if key, e := env.Get(task, "API_KEY"); e == nil && key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	// Create the HTTP client
+	client := &http.Client{
+		// allow insecure tls
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	logging.LogDebug(fmt.Sprintf("Sending POST request to %s", u))
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", usage, fmt.Errorf("failed to send POST request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	// Read the response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", usage, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Unmarshal JSON response into struct
+	var response ChatResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return "", usage, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if response.Error != "" || resp.StatusCode != http.StatusOK {
+		return "", usage, fmt.Errorf("the Ollama chat request failed with status %d: %s", resp.StatusCode, response.Error)
+	}
+
+	usage = message.Usage{InputTokens: int64(response.PromptEvalCount), OutputTokens: int64(response.EvalCount)}
+	return response.Message.Content, usage, nil
+}
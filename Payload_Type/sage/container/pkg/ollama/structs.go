@@ -1,6 +1,7 @@
 package ollama
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -52,3 +53,28 @@ type Details struct {
 	ParameterSize     string   `json:"parameter_size"`
 	QuantizationLevel string   `json:"quantization_level"`
 }
+
+// ChatRequest is the body of a request to Ollama's native /api/chat endpoint
+type ChatRequest struct {
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	// Format is a JSON schema the model's answer is constrained to
+	Format json.RawMessage `json:"format,omitempty"`
+}
+
+// ChatMessage is a single message in a chat with an Ollama model
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatResponse is the body of a non-streaming response from Ollama's /api/chat endpoint
+type ChatResponse struct {
+	Model           string      `json:"model"`
+	Message         ChatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+	Error           string      `json:"error"`
+}
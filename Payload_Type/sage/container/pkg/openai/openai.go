@@ -18,6 +18,9 @@ import (
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
 	sageMCP "github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/mcp"
 	sageMessage "github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/message"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/models"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/ollama"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/schema"
 
 	// Mythic
 	structs "github.com/MythicMeta/MythicContainer/agent_structs"
@@ -147,15 +150,45 @@ func Chat(task *structs.PTTaskMessageAllData, msgs []sageMessage.Message, useToo
 		Stream: false,
 	}
 
-	// Get MCP Tools
+	// Get MCP Tools selected for this session
+	toolbox := sageMCP.NewToolbox(task)
 	if useTools {
 		// Add the tools to the request body
-		req.Tools = mcpTooltoOAITool(sageMCP.GetAllTools())
+		req.Tools = mcpTooltoOAITool(toolbox.Tools())
+	}
+
+	// Constrain the answer to the JSON schema. Ollama's OpenAI compatible endpoint does not take its format parameter,
+	// so the answer from an Ollama server is requested from its native API with the format parameter instead.
+	responseSchema, err := schema.FromTask(task)
+	if err != nil {
+		return response, err
+	}
+	var format json.RawMessage
+	if responseSchema != nil && ollama.IsServer(OPENAI_API_ENDPOINT) {
+		format, err = json.Marshal(responseSchema.Wrap())
+		if err != nil {
+			return response, err
+		}
+	} else if responseSchema != nil {
+		definition, err := json.Marshal(responseSchema.Wrap())
+		if err != nil {
+			return response, err
+		}
+		req.ResponseFormat = &oai.ChatCompletionResponseFormat{
+			Type: oai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &oai.ChatCompletionResponseFormatJSONSchema{
+				Name:        responseSchema.Name,
+				Description: responseSchema.Description,
+				Schema:      json.RawMessage(definition),
+			},
+		}
 	}
 
 	logging.LogInfo(fmt.Sprintf("Using OpenAI provider, calling model: %s, endpoint: %s", model, OPENAI_API_ENDPOINT))
 
-	done := false
+	// Without tools, the format constrained answer from Ollama is the only request
+	done := format != nil && len(req.Tools) == 0
+	var usage sageMessage.Usage
 	for !done {
 		var resp oai.ChatCompletionResponse
 		req.Messages = messages
@@ -165,6 +198,7 @@ func Chat(task *structs.PTTaskMessageAllData, msgs []sageMessage.Message, useToo
 			err = fmt.Errorf("chatCompletion error: %v", err)
 			return
 		}
+		usage = usage.Add(sageMessage.Usage{InputTokens: int64(resp.Usage.PromptTokens), OutputTokens: int64(resp.Usage.CompletionTokens)})
 		if len(resp.Choices) <= 0 {
 			done = true
 			break
@@ -180,22 +214,32 @@ func Chat(task *structs.PTTaskMessageAllData, msgs []sageMessage.Message, useToo
 				logging.LogDebug("FinishResonLength", choice.Message.Content)
 			case oai.FinishReasonToolCalls:
 				messages = append(messages, choice.Message)
+				// Tool messages can only contain text so images are sent to the model in a user message after the tool responses
+				var images []oai.ChatMessagePart
 				for _, toolCall := range choice.Message.ToolCalls {
 					response = append(response, sageMessage.Message{
 						Role:    sageMessage.Assistant,
 						Content: fmt.Sprintf("🛠️ Tool Call - ID: %s, Type: %s, Name: %s, Arguments: %s", toolCall.ID, toolCall.Type, toolCall.Function.Name, toolCall.Function.Arguments),
+						Kind:    sageMessage.ToolCall,
+						Tool:    toolCall.Function.Name,
 					})
 					var toolResponse oai.ChatCompletionMessage
-					toolResponse, err = toolUse(toolCall)
-					if err != nil {
-						err = fmt.Errorf("toolUse error: %v", err)
-						return
-					}
+					var parts []oai.ChatMessagePart
+					toolResponse, parts = toolUse(toolCall, toolbox)
 					// Add the tool responses to the messages
 					messages = append(messages, toolResponse)
 					response = append(response, sageMessage.Message{
 						Role:    sageMessage.Assistant,
 						Content: fmt.Sprintf("🛠️ Tool Call Result: %s", toolResponse.Content),
+						Kind:    sageMessage.ToolResult,
+						Tool:    toolCall.Function.Name,
+					})
+					images = append(images, parts...)
+				}
+				if len(images) > 0 {
+					messages = append(messages, oai.ChatCompletionMessage{
+						Role:         oai.ChatMessageRoleUser,
+						MultiContent: images,
 					})
 				}
 			case oai.FinishReasonContentFilter:
@@ -212,21 +256,47 @@ func Chat(task *structs.PTTaskMessageAllData, msgs []sageMessage.Message, useToo
 		if len(resp.Choices) > 0 {
 			for _, choice := range resp.Choices {
 				if choice.FinishReason != oai.FinishReasonToolCalls && choice.Message.Content != "" {
+					content := choice.Message.Content
+					if responseSchema != nil {
+						content = string(responseSchema.Unwrap(json.RawMessage(content)))
+					}
 					response = append(response, sageMessage.Message{
 						Role:    sageMessage.Assistant,
-						Content: choice.Message.Content,
+						Content: content,
 					})
 				}
 			}
 		}
 	}
 
+	// The model's answer, after any tool calls, is given back with the format parameter to get the JSON answer
+	if format != nil {
+		history := append(append([]sageMessage.Message{}, msgs...), response...)
+		if len(response) > 0 {
+			history = append(history, sageMessage.Message{Role: sageMessage.User, Content: responseSchema.Instructions()})
+		}
+		answer, formatUsage, err := ollama.ChatFormat(task, model, history, format)
+		if err != nil {
+			return response, err
+		}
+		usage = usage.Add(formatUsage)
+		response = append(response, sageMessage.Message{
+			Role:    sageMessage.Assistant,
+			Content: string(responseSchema.Unwrap(json.RawMessage(answer))),
+		})
+	}
+
+	if len(response) > 0 {
+		response[len(response)-1].Usage = &usage
+	}
+
 	//logging.LogDebug(fmt.Sprintf("ChatCompletion Response (%d): %s", len(response), response))
 	logging.LogDebug("OpenAI Chat Completion Response", "Count", len(response))
 	return
 }
 
-func List(task *structs.PTTaskMessageAllData) (output string, err error) {
+// List returns the models from the OpenAI compatible /models endpoint
+func List(task *structs.PTTaskMessageAllData) (list []models.Model, err error) {
 	c, err := GetClient(task)
 	if err != nil {
 		return
@@ -234,17 +304,17 @@ func List(task *structs.PTTaskMessageAllData) (output string, err error) {
 
 	ctx := context.Background()
 
-	models, err := c.ListModels(ctx)
+	result, err := c.ListModels(ctx)
 	if err != nil {
 		err = fmt.Errorf("ListModels error: %v", err)
 		return
 	}
 
-	for _, m := range models.Models {
-		output += fmt.Sprintf("%s\n", m.ID)
+	for _, m := range result.Models {
+		list = append(list, models.Model{ID: m.ID, Created: models.Unix(m.CreatedAt)})
 	}
 
-	logging.LogDebug(fmt.Sprintf("ListModels Response: %s", output))
+	logging.LogDebug(fmt.Sprintf("ListModels Response: %+v", list))
 	return
 }
 
@@ -267,21 +337,28 @@ func mcpTooltoOAITool(mcpTools []mcp.Tool) (tools []oai.Tool) {
 	return
 }
 
-func toolUse(call oai.ToolCall) (message oai.ChatCompletionMessage, err error) {
-	// Convert JSON to map
-	var args map[string]interface{}
-	err = json.Unmarshal([]byte(call.Function.Arguments), &args)
-	if err != nil {
-		return
-	}
-	var toolResponse string
-	toolResponse, err = sageMCP.ExecuteTool(call.Function.Name, args)
-	if err != nil {
-		return
+// toolUse executes the tool call and returns the tool message for the model.
+// A failed call is returned as an error message for the model instead of an error.
+func toolUse(call oai.ToolCall, toolbox *sageMCP.Toolbox) (message oai.ChatCompletionMessage, images []oai.ChatMessagePart) {
+	result := toolbox.Call(call.Function.Name, []byte(call.Function.Arguments))
+	toolResponse := result.Text
+	if result.IsError {
+		toolResponse = fmt.Sprintf("The tool returned an error: %s", toolResponse)
 	}
-	if toolResponse == "" {
+	if toolResponse == "" && len(result.Images) == 0 {
 		toolResponse = "success"
 	}
+	for _, image := range result.Images {
+		images = append(images, oai.ChatMessagePart{
+			Type: oai.ChatMessagePartTypeImageURL,
+			ImageURL: &oai.ChatMessageImageURL{
+				URL: fmt.Sprintf("data:%s;base64,%s", image.MIMEType, image.Data),
+			},
+		})
+	}
+	if len(images) > 0 {
+		toolResponse += fmt.Sprintf("\nThe tool returned %d image(s) that are attached in the next message", len(images))
+	}
 	message = oai.ChatCompletionMessage{
 		Role:       oai.ChatMessageRoleTool,
 		ToolCallID: call.ID,
@@ -15,6 +15,7 @@ import (
 
 	// Internal
 	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/env"
+	"github.com/MythicAgents/sage/Payload_Type/sage/container/pkg/models"
 
 	// Mythic
 	structs "github.com/MythicMeta/MythicContainer/agent_structs"
@@ -192,29 +193,30 @@ func Chat(task *structs.PTTaskMessageAllData, prompt string) (output string, err
 	return
 }
 
-func List(task *structs.PTTaskMessageAllData) (output string, err error) {
+// List returns the models available through Open WebUI
+func List(task *structs.PTTaskMessageAllData) (list []models.Model, err error) {
 	// Get the OPEN_WEBUI_API_KEY
 	OPEN_WEBUI_API_KEY, err := env.Get(task, "API_KEY")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Get the OPEN_WEBUI_API_ENDPOINT
 	OPEN_WEBUI_API_ENDPOINT, err := env.Get(task, "API_ENDPOINT")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	endpoint := "api/models"
 
 	parsedBase, err := url.Parse(OPEN_WEBUI_API_ENDPOINT)
 	if err != nil {
-		return "", fmt.Errorf("invalid base URL: %w", err)
+		return nil, fmt.Errorf("invalid base URL: %w", err)
 	}
 
 	parsedEndpoint, err := url.Parse(endpoint)
 	if err != nil {
-		return "", fmt.Errorf("invalid endpoint: %w", err)
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
 	}
 
 	// Ensure the endpoint is properly joined with the base URL
@@ -223,7 +225,7 @@ func List(task *structs.PTTaskMessageAllData) (output string, err error) {
 	// Create the GET request
 	req, err := http.NewRequest("GET", parsedBase.String(), nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GET request: %s", err)
+		return nil, fmt.Errorf("failed to create GET request: %s", err)
 	}
 
 	// Set the request headers
@@ -240,26 +242,26 @@ func List(task *structs.PTTaskMessageAllData) (output string, err error) {
 	logging.LogDebug(fmt.Sprintf("Sending GET request to %s", parsedBase.String()))
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send GET request: %s", err)
+		return nil, fmt.Errorf("failed to send GET request: %s", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	// Unmarshal JSON response into struct
 	var response Response
 	err = json.Unmarshal(body, &response)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal JSON: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
 	}
 	logging.LogDebug(fmt.Sprintf("Data: %+v", response))
 
 	for _, d := range response.Data {
-		output += fmt.Sprintf("ID: %s\n", d.ID)
+		list = append(list, models.Model{ID: d.ID, Name: d.Name, Created: models.Unix(d.Created)})
 	}
 	return
 }
@@ -0,0 +1,154 @@
+// Package schema holds the JSON schemas a model's answer can be constrained to and validates the answers against them
+package schema
+
+import (
+	// Standard
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	// Mythic
+	structs "github.com/MythicMeta/MythicContainer/agent_structs"
+)
+
+// Arg is the task argument with the JSON schema, or the name of a built-in schema, the model's answer must follow
+const Arg = "schema"
+
+// MaxAttempts is how many times the model is asked for a valid answer before the task fails
+const MaxAttempts = 3
+
+// Schema is a JSON schema the model's answer must follow
+type Schema struct {
+	// Name identifies the schema to the provider; it is only letters, numbers, underscores, and dashes
+	Name string
+	// Description tells the model what the answer is for
+	Description string
+	// Definition is the JSON schema document
+	Definition map[string]interface{}
+}
+
+// builtins are the named schemas operators can select instead of writing their own
+var builtins = make(map[string]Schema)
+
+// Register adds a named schema operators can select with the "schema" argument.
+// The definition is round-tripped through JSON so it has the same types as a schema provided by an operator.
+// It panics if the schema uses a keyword that is not validated, which is a mistake in Sage's built-in schemas.
+func Register(s Schema) {
+	if b, err := json.Marshal(s.Definition); err == nil {
+		s.Definition = nil
+		_ = json.Unmarshal(b, &s.Definition)
+	}
+	if err := check(s.Definition, "$"); err != nil {
+		panic(fmt.Sprintf("the built-in %s schema is not valid: %s", s.Name, err))
+	}
+	builtins[s.Name] = s
+}
+
+// Names returns the names of the built-in schemas
+func Names() (names []string) {
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// invalidNameChars matches the characters providers do not allow in schema and tool names
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// FromTask returns the schema from the task's "schema" argument, or nil if the task does not constrain the answer.
+// The argument is either the name of a built-in schema or a JSON schema document.
+func FromTask(task *structs.PTTaskMessageAllData) (*Schema, error) {
+	value, err := task.Args.GetStringArg(Arg)
+	if err != nil || strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	value = strings.TrimSpace(value)
+	if s, ok := builtins[value]; ok {
+		return &s, nil
+	}
+	s := Schema{Name: "response", Description: "The answer to the user's request"}
+	if err = json.Unmarshal([]byte(value), &s.Definition); err != nil {
+		return nil, fmt.Errorf("the schema is not a built-in schema (%s) or a valid JSON schema: %w", strings.Join(Names(), ", "), err)
+	}
+	if err = check(s.Definition, "$"); err != nil {
+		return nil, err
+	}
+	if title, ok := s.Definition["title"].(string); ok && strings.TrimSpace(title) != "" {
+		s.Name = invalidNameChars.ReplaceAllString(strings.TrimSpace(title), "_")
+		if len(s.Name) > 64 {
+			s.Name = s.Name[:64]
+		}
+	}
+	if description, ok := s.Definition["description"].(string); ok && description != "" {
+		s.Description = description
+	}
+	return &s, nil
+}
+
+// IsObject returns true if the schema's root is a JSON object.
+// Providers that require an object, like Anthropic tool inputs, wrap other schemas with Wrap.
+func (s Schema) IsObject() bool {
+	t, _ := s.Definition["type"].(string)
+	return t == "object"
+}
+
+// Wrap returns the schema as an object with a single "result" property so it can be used where an object is required
+func (s Schema) Wrap() map[string]interface{} {
+	if s.IsObject() {
+		return s.Definition
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           map[string]interface{}{"result": s.Definition},
+		"required":             []string{"result"},
+		"additionalProperties": false,
+	}
+}
+
+// Unwrap returns the answer to a schema returned by Wrap as the answer to the original schema
+func (s Schema) Unwrap(answer json.RawMessage) json.RawMessage {
+	if s.IsObject() {
+		return answer
+	}
+	var wrapped struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(answer, &wrapped); err != nil || wrapped.Result == nil {
+		return answer
+	}
+	return wrapped.Result
+}
+
+// Instructions returns the message that asks the model to answer with JSON that follows the schema.
+// It is used when the provider can not enforce the schema itself and when the model's answer was invalid.
+func (s Schema) Instructions() string {
+	definition, _ := json.MarshalIndent(s.Definition, "", "  ")
+	return fmt.Sprintf("Answer with a single JSON value, without any other text or Markdown, that follows this JSON schema:\n%s", definition)
+}
+
+// Validate parses the model's answer and returns it pretty-printed if it follows the schema.
+// A Markdown code fence around the JSON is tolerated. The error explains every violation so it can be given back to the model.
+func (s Schema) Validate(answer string) (string, error) {
+	answer = strings.TrimSpace(answer)
+	if strings.HasPrefix(answer, "```") {
+		answer = strings.TrimPrefix(answer, "```json")
+		answer = strings.TrimPrefix(answer, "```")
+		answer = strings.TrimSuffix(strings.TrimSpace(answer), "```")
+	}
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(answer), &data); err != nil {
+		return "", fmt.Errorf("the answer is not valid JSON: %w", err)
+	}
+	if violations := validate(s.Definition, data, "$"); len(violations) > 0 {
+		return "", fmt.Errorf("the answer does not follow the schema:\n- %s", strings.Join(violations, "\n- "))
+	}
+	pretty, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(pretty), nil
+}
@@ -0,0 +1,226 @@
+package schema
+
+import (
+	// Standard
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"unicode/utf8"
+)
+
+// keywords are the JSON schema keywords validate checks
+var keywords = []string{"type", "enum", "const", "properties", "required", "additionalProperties", "items", "anyOf", "minItems", "maxItems", "minLength", "maxLength", "minimum", "maximum"}
+
+// annotations are the JSON schema keywords that do not constrain the value, so they are allowed but not checked
+var annotations = []string{"title", "description", "default", "examples", "$schema", "$id", "$comment", "readOnly", "writeOnly", "deprecated"}
+
+// check returns an error for the first keyword in the schema, or its subschemas, that validate does not support, like
+// pattern, format, oneOf, allOf, or $ref. A schema with those keywords is rejected instead of letting answers that
+// break them pass validation.
+func check(schema map[string]interface{}, path string) error {
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !slices.Contains(keywords, name) && !slices.Contains(annotations, name) {
+			return fmt.Errorf("the schema keyword '%s' at %s is not supported, use only: %v", name, path, keywords)
+		}
+	}
+
+	var subschemas []string
+	properties, _ := schema["properties"].(map[string]interface{})
+	for name := range properties {
+		subschemas = append(subschemas, name)
+	}
+	sort.Strings(subschemas)
+	for _, name := range subschemas {
+		if p, ok := properties[name].(map[string]interface{}); ok {
+			if err := check(p, path+".properties."+name); err != nil {
+				return err
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		if err := check(items, path+".items"); err != nil {
+			return err
+		}
+	}
+	if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		if err := check(additional, path+".additionalProperties"); err != nil {
+			return err
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		for i, option := range anyOf {
+			if s, ok := option.(map[string]interface{}); ok {
+				if err := check(s, fmt.Sprintf("%s.anyOf[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validate returns every way the data violates the schema with the JSON path of the value.
+// It supports the keywords models are asked to follow, which are listed in keywords; schemas with other keywords are
+// rejected by check before they are used.
+func validate(schema map[string]interface{}, data interface{}, path string) (violations []string) {
+	if schema == nil {
+		return nil
+	}
+
+	if t, ok := schema["type"]; ok {
+		var types []string
+		switch v := t.(type) {
+		case string:
+			types = []string{v}
+		case []interface{}:
+			for _, i := range v {
+				if s, ok := i.(string); ok {
+					types = append(types, s)
+				}
+			}
+		}
+		if len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return isType(t, data) }) {
+			return []string{fmt.Sprintf("%s must be of type %v but is %s", path, types, typeOf(data))}
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		if !slices.ContainsFunc(enum, func(e interface{}) bool { return reflect.DeepEqual(e, data) }) {
+			violations = append(violations, fmt.Sprintf("%s must be one of %s", path, marshal(enum)))
+		}
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, data) {
+		violations = append(violations, fmt.Sprintf("%s must be %s", path, marshal(c)))
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, option := range anyOf {
+			if s, ok := option.(map[string]interface{}); ok && len(validate(s, data, path)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			violations = append(violations, fmt.Sprintf("%s does not match any of the allowed schemas", path))
+		}
+	}
+
+	switch v := data.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if name, ok := r.(string); ok {
+					if _, exists := v[name]; !exists {
+						violations = append(violations, fmt.Sprintf("%s is missing the required property '%s'", path, name))
+					}
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if p, ok := properties[key].(map[string]interface{}); ok {
+				violations = append(violations, validate(p, v[key], path+"."+key)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					violations = append(violations, fmt.Sprintf("%s has the property '%s' that is not in the schema", path, key))
+				}
+			case map[string]interface{}:
+				violations = append(violations, validate(additional, v[key], path+"."+key)...)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				violations = append(violations, validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+		if min, ok := number(schema["minItems"]); ok && float64(len(v)) < min {
+			violations = append(violations, fmt.Sprintf("%s must have at least %v items", path, min))
+		}
+		if max, ok := number(schema["maxItems"]); ok && float64(len(v)) > max {
+			violations = append(violations, fmt.Sprintf("%s must have at most %v items", path, max))
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if min, ok := number(schema["minLength"]); ok && length < min {
+			violations = append(violations, fmt.Sprintf("%s must be at least %v characters", path, min))
+		}
+		if max, ok := number(schema["maxLength"]); ok && length > max {
+			violations = append(violations, fmt.Sprintf("%s must be at most %v characters", path, max))
+		}
+	case float64:
+		if min, ok := number(schema["minimum"]); ok && v < min {
+			violations = append(violations, fmt.Sprintf("%s must be at least %v", path, min))
+		}
+		if max, ok := number(schema["maximum"]); ok && v > max {
+			violations = append(violations, fmt.Sprintf("%s must be at most %v", path, max))
+		}
+	}
+	return
+}
+
+// isType returns true if the decoded JSON value is of the JSON schema type
+func isType(t string, data interface{}) bool {
+	switch t {
+	case "integer":
+		f, ok := data.(float64)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := data.(float64)
+		return ok
+	default:
+		return typeOf(data) == t
+	}
+}
+
+// typeOf returns the JSON schema type of the decoded JSON value
+func typeOf(data interface{}) string {
+	switch data.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", data)
+	}
+}
+
+// number returns the schema keyword's value as a float64
+func number(v interface{}) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+// marshal returns the value as JSON for error messages
+func marshal(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
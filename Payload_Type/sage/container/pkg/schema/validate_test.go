@@ -0,0 +1,116 @@
+package schema
+
+import (
+	// Standard
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// parse unmarshals a JSON schema or value the same way FromTask and Validate do
+func parse(t *testing.T, s string) (v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("%s is not valid JSON: %s", s, err)
+	}
+	return v
+}
+
+// TestValidate checks each supported keyword with an answer that follows it and one that does not
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		data   string
+		// violation is a substring of the expected violation, or empty if the data is valid
+		violation string
+	}{
+		{"type", `{"type":"string"}`, `"a"`, ""},
+		{"type mismatch", `{"type":"string"}`, `1`, "$ must be of type [string] but is number"},
+		{"type list", `{"type":["string","null"]}`, `null`, ""},
+		{"integer", `{"type":"integer"}`, `1.5`, "must be of type [integer]"},
+		{"enum", `{"enum":["a","b"]}`, `"b"`, ""},
+		{"enum mismatch", `{"enum":["a","b"]}`, `"c"`, `$ must be one of ["a","b"]`},
+		{"const", `{"const":1}`, `2`, "$ must be 1"},
+		{"required", `{"type":"object","required":["a"]}`, `{}`, "a"},
+		{"properties", `{"type":"object","properties":{"a":{"type":"number"}}}`, `{"a":"x"}`, "$.a must be of type [number]"},
+		{"additionalProperties false", `{"type":"object","properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"b":2}`, "b"},
+		{"additionalProperties schema", `{"type":"object","additionalProperties":{"type":"string"}}`, `{"b":2}`, "$.b must be of type [string]"},
+		{"items", `{"type":"array","items":{"type":"string"}}`, `["a",1]`, "$[1] must be of type [string]"},
+		{"minItems", `{"type":"array","minItems":2}`, `["a"]`, "$"},
+		{"maxItems", `{"type":"array","maxItems":1}`, `["a","b"]`, "$"},
+		{"minLength", `{"type":"string","minLength":2}`, `"a"`, "$"},
+		{"maxLength counts characters", `{"type":"string","maxLength":1}`, `"é"`, ""},
+		{"minimum", `{"type":"number","minimum":1}`, `0`, "$"},
+		{"maximum", `{"type":"number","maximum":1}`, `1`, ""},
+		{"anyOf", `{"anyOf":[{"type":"string"},{"type":"number"}]}`, `1`, ""},
+		{"anyOf mismatch", `{"anyOf":[{"type":"string"},{"type":"number"}]}`, `true`, "$"},
+		{"annotations", `{"type":"string","description":"d","title":"t","default":"x"}`, `"a"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := parse(t, tt.schema).(map[string]interface{})
+			if err := check(schema, "$"); err != nil {
+				t.Fatalf("check returned an error for a supported schema: %s", err)
+			}
+			violations := validate(schema, parse(t, tt.data), "$")
+			if tt.violation == "" {
+				if len(violations) > 0 {
+					t.Errorf("validate returned %v for valid data %s", violations, tt.data)
+				}
+				return
+			}
+			if len(violations) == 0 {
+				t.Fatalf("validate returned no violations for %s, expected one containing %q", tt.data, tt.violation)
+			}
+			if !strings.Contains(strings.Join(violations, "\n"), tt.violation) {
+				t.Errorf("validate returned %v, expected a violation containing %q", violations, tt.violation)
+			}
+		})
+	}
+}
+
+// TestCheckUnsupported checks that schemas using keywords validate does not support are rejected wherever they are nested
+func TestCheckUnsupported(t *testing.T) {
+	tests := map[string]string{
+		"pattern":              `{"type":"string","pattern":"^a"}`,
+		"format":               `{"type":"string","format":"email"}`,
+		"oneOf":                `{"oneOf":[{"type":"string"}]}`,
+		"allOf":                `{"allOf":[{"type":"string"}]}`,
+		"$ref":                 `{"$ref":"#/$defs/a","$defs":{"a":{}}}`,
+		"nested property":      `{"type":"object","properties":{"a":{"type":"string","pattern":"^a"}}}`,
+		"items":                `{"type":"array","items":{"format":"uri"}}`,
+		"additionalProperties": `{"type":"object","additionalProperties":{"oneOf":[]}}`,
+		"anyOf":                `{"anyOf":[{"type":"string","format":"date"}]}`,
+	}
+	for name, s := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := check(parse(t, s).(map[string]interface{}), "$"); err == nil {
+				t.Errorf("check accepted %s", s)
+			}
+		})
+	}
+
+	// A property named like an unsupported keyword is not a keyword
+	if err := check(parse(t, `{"type":"object","properties":{"pattern":{"type":"string"}}}`).(map[string]interface{}), "$"); err != nil {
+		t.Errorf("check rejected a property named pattern: %s", err)
+	}
+}
+
+// TestSchemaValidate checks that answers wrapped in a Markdown code block are validated and formatted
+func TestSchemaValidate(t *testing.T) {
+	s := Schema{Definition: parse(t, `{"type":"object","required":["a"]}`).(map[string]interface{})}
+	answer, err := s.Validate("```json\n{\"a\":1}\n```")
+	if err != nil {
+		t.Fatalf("Validate returned an error: %s", err)
+	}
+	if answer != "{\n  \"a\": 1\n}" {
+		t.Errorf("Validate returned %q", answer)
+	}
+	if _, err = s.Validate("not json"); err == nil {
+		t.Errorf("Validate accepted an answer that is not JSON")
+	}
+	if _, err = s.Validate(`{}`); err == nil {
+		t.Errorf("Validate accepted an answer without a required property")
+	}
+}